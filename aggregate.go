@@ -0,0 +1,113 @@
+// Copyright 2014 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package btree
+
+// Monoid describes how to aggregate the items of a tree, for Aggregate: each
+// item has a value given by Measure, and values are combined with Combine,
+// starting from Identity.
+//
+// Combine must be associative, and Identity must be an identity for it:
+// Combine(Identity, v) and Combine(v, Identity) are both v.  Combine need not
+// be commutative; values are always combined in item order.  For example, a
+// sum of sizes has Identity 0 and Combine returning a+b, and a maximum might
+// use nil as Identity.
+type Monoid struct {
+	Identity interface{}
+	Measure  func(item Item) interface{}
+	Combine  func(a, b interface{}) interface{}
+}
+
+// SetMonoid sets the Monoid that t aggregates its items with, or stops
+// aggregating if m is nil.
+//
+// Each node then caches the aggregate of its subtree, kept up to date by
+// every write, at the cost of recomputing it from the node's items and
+// children on each node a write touches.  SetMonoid computes the caches of
+// the whole tree, in O(n) time, copying any nodes shared with clones.  Clones
+// of t use the same Monoid.
+func (t *BTree) SetMonoid(m *Monoid) {
+	t.cow.monoid = m
+	t.mods++
+	if t.root != nil {
+		t.root = t.root.remeasure(t.cow)
+	}
+}
+
+// remeasure recomputes the aggregates of the subtree rooted at n for c's
+// monoid, making each node mutable for c first.
+func (n *node) remeasure(c *copyOnWriteContext) *node {
+	n = n.mutableFor(c)
+	for i, child := range n.children {
+		n.children[i] = child.remeasure(c)
+	}
+	n.agg = nil
+	n.reaggregate()
+	return n
+}
+
+// Aggregate returns the aggregate of the items in the range [greaterOrEqual,
+// lessThan) under t's Monoid, combined in order: the Monoid's Identity if
+// the range is empty, or else Combine(...Combine(Measure(a), Measure(b))...).
+// A nil bound leaves that end of the range open, so Aggregate(nil, nil)
+// aggregates the whole tree.
+//
+// Aggregate takes O(log n) time, combining cached aggregates for the subtrees
+// wholly within the range.  It panics if t has no Monoid.
+func (t *BTree) Aggregate(greaterOrEqual, lessThan Item) interface{} {
+	m := t.cow.monoid
+	if m == nil {
+		panic("btree: Aggregate called on a tree without a Monoid")
+	}
+	if t.root == nil || (greaterOrEqual != nil && lessThan != nil && !greaterOrEqual.Less(lessThan)) {
+		return m.Identity
+	}
+	return t.root.aggregate(m, greaterOrEqual, lessThan)
+}
+
+// aggregate returns the aggregate of the items in the subtree within the
+// range [ge, lt), where either bound may be nil.  Only the children holding
+// the range's ends are descended into; those between them, which lie wholly
+// within it, contribute their cached aggregates.
+func (n *node) aggregate(m *Monoid, ge, lt Item) interface{} {
+	if ge == nil && lt == nil {
+		return n.agg
+	}
+	i, j := 0, len(n.items)
+	if ge != nil {
+		i = n.items.lowerBound(ge)
+	}
+	if lt != nil {
+		j = n.items.lowerBound(lt)
+	}
+	acc := m.Identity
+	if len(n.children) == 0 {
+		for _, item := range n.items[i:j] {
+			acc = m.Combine(acc, m.Measure(item))
+		}
+		return acc
+	}
+	if i == j {
+		// The whole range falls within a single child.
+		return n.children[i].aggregate(m, ge, lt)
+	}
+	acc = m.Combine(acc, n.children[i].aggregate(m, ge, nil))
+	for k := i; k < j; k++ {
+		acc = m.Combine(acc, m.Measure(n.items[k]))
+		if k+1 < j {
+			acc = m.Combine(acc, n.children[k+1].agg)
+		}
+	}
+	return m.Combine(acc, n.children[j].aggregate(m, nil, lt))
+}
@@ -0,0 +1,204 @@
+// Copyright 2014 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package btree
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// sumVals sums the values of pair items.
+var sumVals = &Monoid{
+	Identity: 0,
+	Measure:  func(a Item) interface{} { return a.(pair).val },
+	Combine:  func(a, b interface{}) interface{} { return a.(int) + b.(int) },
+}
+
+// keyList lists the keys of pair items in order, to check that aggregates
+// are combined in order.
+var keyList = &Monoid{
+	Identity: []int(nil),
+	Measure:  func(a Item) interface{} { return []int{a.(pair).key} },
+	Combine: func(a, b interface{}) interface{} {
+		return append(append([]int(nil), a.([]int)...), b.([]int)...)
+	},
+}
+
+// checkAggs checks the cached aggregate of every node in the subtree against
+// one computed from scratch.
+func checkAggs(t *testing.T, n *node, m *Monoid) interface{} {
+	t.Helper()
+	acc := m.Identity
+	for i, item := range n.items {
+		if len(n.children) > 0 {
+			acc = m.Combine(acc, checkAggs(t, n.children[i], m))
+		}
+		acc = m.Combine(acc, m.Measure(item))
+	}
+	if len(n.children) > 0 {
+		acc = m.Combine(acc, checkAggs(t, n.children[len(n.items)], m))
+	}
+	if !reflect.DeepEqual(acc, n.agg) {
+		t.Fatalf("node %v caches aggregate %v, want %v", n.items, n.agg, acc)
+	}
+	return acc
+}
+
+// sumRange sums the values of the pairs with keys in [lo, hi) by scanning.
+func sumRange(tr *BTree, lo, hi int) (sum int) {
+	tr.Ascend(func(a Item) bool {
+		if p := a.(pair); p.key >= lo && p.key < hi {
+			sum += p.val
+		}
+		return true
+	})
+	return sum
+}
+
+func TestAggregate(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	tr := New(2)
+	tr.SetMonoid(sumVals)
+	var clones []*BTree
+	for i := 0; i < 3000; i++ {
+		k := r.Intn(500)
+		switch op := r.Intn(10); op {
+		case 0, 1, 2, 3:
+			tr.ReplaceOrInsert(pair{k, r.Intn(100)})
+		case 4:
+			tr.Delete(pair{k, 0})
+		case 5:
+			if tr.Len() > 0 {
+				tr.DeleteAt(r.Intn(tr.Len()))
+			}
+		case 6:
+			tr.DeleteRange(pair{k, 0}, pair{k + r.Intn(20), 0})
+		case 7:
+			if r.Intn(2) == 0 {
+				tr.DeleteMin()
+			} else {
+				tr.DeleteMax()
+			}
+		case 8:
+			clones = append(clones, tr.Clone())
+		case 9:
+			l, rt := tr.SplitAt(pair{k, 0})
+			tr = Join(l, rt)
+		}
+		if tr.root != nil {
+			checkAggs(t, tr.root, sumVals)
+		}
+		lo, hi := r.Intn(520)-10, r.Intn(520)-10
+		if got, want := tr.Aggregate(pair{lo, 0}, pair{hi, 0}), sumRange(tr, lo, hi); got != want {
+			t.Fatalf("op %d: Aggregate(%d, %d) = %v, want %v", i, lo, hi, got, want)
+		}
+	}
+	if got, want := tr.Aggregate(nil, nil), sumRange(tr, -1, 1000); got != want {
+		t.Fatalf("Aggregate(nil, nil) = %v, want %v", got, want)
+	}
+	if got, want := tr.Aggregate(pair{250, 0}, nil), sumRange(tr, 250, 1000); got != want {
+		t.Fatalf("Aggregate(250, nil) = %v, want %v", got, want)
+	}
+	for _, c := range clones {
+		if c.root != nil {
+			checkAggs(t, c.root, sumVals)
+		}
+	}
+}
+
+func TestAggregateFreedNodes(t *testing.T) {
+	f := NewFreeList(1000)
+	tr := NewWithFreeList(2, f)
+	tr.SetMonoid(keyList)
+	for _, v := range perm(200) {
+		tr.ReplaceOrInsert(pair{int(v.(Int)), 0})
+	}
+	tr.Clear(true)
+	// Freed nodes mustn't hold on to their aggregates.
+	freed, _ := f.stats()
+	for i := 0; i < freed; i++ {
+		if n := f.newNode(); n.agg != nil {
+			t.Fatalf("freed node kept its aggregate %v", n.agg)
+		}
+	}
+}
+
+func TestAggregateOrder(t *testing.T) {
+	tr := NewMulti(3)
+	for _, v := range perm(200) {
+		tr.InsertNoReplace(pair{int(v.(Int)) / 2, 0})
+	}
+	tr.SetMonoid(keyList)
+	checkAggs(t, tr.root, keyList)
+	var want []int
+	for i := 20; i < 150; i++ {
+		want = append(want, i/2)
+	}
+	if got := tr.Aggregate(pair{10, 0}, pair{75, 0}); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v\nwant %v", got, want)
+	}
+	if got := tr.Aggregate(pair{75, 0}, pair{10, 0}); got != nil && len(got.([]int)) != 0 {
+		t.Fatalf("empty range: got %v", got)
+	}
+}
+
+func TestAggregateBulk(t *testing.T) {
+	a, b := New(3), New(3)
+	a.SetMonoid(sumVals)
+	b.SetMonoid(sumVals)
+	for i := 0; i < 1000; i++ {
+		a.ReplaceOrInsert(pair{i * 2, i})
+		b.ReplaceOrInsert(pair{i * 3, 1})
+	}
+	for _, tr := range []*BTree{Union(a, b, sumPairs), Intersect(a, b, sumPairs), Difference(a, b)} {
+		checkAggs(t, tr.root, sumVals)
+		if got, want := tr.Aggregate(pair{100, 0}, pair{1900, 0}), sumRange(tr, 100, 1900); got != want {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+	}
+
+	txn := a.Begin()
+	txn.DeleteRange(pair{0, 0}, pair{1000, 0})
+	txn.ReplaceOrInsert(pair{5000, 7})
+	if err := txn.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	checkAggs(t, a.root, sumVals)
+	if got, want := a.Aggregate(nil, nil), sumRange(a, 0, 10000); got != want {
+		t.Fatalf("after Commit: got %v, want %v", got, want)
+	}
+
+	a.SetMonoid(nil)
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Aggregate without a Monoid didn't panic")
+		}
+	}()
+	a.Aggregate(nil, nil)
+}
+
+func BenchmarkAggregate(b *testing.B) {
+	tr := New(*btreeDegree)
+	tr.SetMonoid(sumVals)
+	for i, v := range perm(benchmarkTreeSize) {
+		tr.ReplaceOrInsert(pair{int(v.(Int)), i})
+	}
+	lo, hi := Item(pair{benchmarkTreeSize / 4, 0}), Item(pair{3 * benchmarkTreeSize / 4, 0})
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tr.Aggregate(lo, hi)
+	}
+}
@@ -38,21 +38,25 @@
 // These issues don't tend to matter, though, when working with strings or other
 // heap-allocated structures, since C++-equivalent structures also must store
 // pointers and also distribute their values across the heap.
+// BTreeG avoids both issues: it is parameterized over the item type T and
+// ordered by a LessFunc, so its nodes store a []T directly.
+// NewFixed goes one step further for fixed-size keys such as int64 or UUID,
+// searching each node without calling a LessFunc at all.
 //
 // This implementation is designed to be a drop-in replacement to gollrb.LLRB
 // trees, (http://github.com/petar/gollrb), an excellent and probably the most
 // widely used ordered tree implementation in the Go ecosystem currently.
 // Its functions, therefore, exactly mirror those of
-// llrb.LLRB where possible.  Unlike gollrb, though, we currently don't
-// support storing multiple equivalent values.
+// llrb.LLRB where possible.  Like gollrb, trees created with NewMulti can store
+// multiple equivalent values.
 package btree
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"sort"
 	"strings"
-	"sync"
 )
 
 // Item represents a single object in the tree.
@@ -61,7 +65,7 @@ type Item interface {
 	//
 	// This must provide a strict weak ordering.
 	// If !a.Less(b) && !b.Less(a), we treat this to mean a == b (i.e. we can only
-	// hold one of either a or b in the tree).
+	// hold one of either a or b in the tree, unless it was created by NewMulti).
 	Less(than Item) bool
 }
 
@@ -78,37 +82,36 @@ var (
 // BTree has its own FreeList, but multiple BTrees can share the same
 // FreeList.
 // Two Btrees using the same freelist are safe for concurrent write access.
+// The list is split into shards, one for each P in GOMAXPROCS when it was
+// created, so such writers seldom contend for the same lock.
 type FreeList struct {
-	mu       sync.Mutex
-	freelist []*node
+	pool nodePool[node]
 }
 
 // NewFreeList creates a new free list.
 // size is the maximum size of the returned free list.
 func NewFreeList(size int) *FreeList {
-	return &FreeList{freelist: make([]*node, 0, size)}
+	f := new(FreeList)
+	f.pool.init(size)
+	return f
 }
 
 func (f *FreeList) newNode() (n *node) {
-	f.mu.Lock()
-	index := len(f.freelist) - 1
-	if index < 0 {
-		f.mu.Unlock()
-		return new(node)
-	}
-	n = f.freelist[index]
-	f.freelist[index] = nil
-	f.freelist = f.freelist[:index]
-	f.mu.Unlock()
+	if n = f.pool.get(); n == nil {
+		n = new(node)
+	}
 	return
 }
 
-func (f *FreeList) freeNode(n *node) {
-	f.mu.Lock()
-	if len(f.freelist) < cap(f.freelist) {
-		f.freelist = append(f.freelist, n)
-	}
-	f.mu.Unlock()
+// freeNode adds the given node to the list, returning true if it was added
+// and false if it was discarded.
+func (f *FreeList) freeNode(n *node) (out bool) {
+	return f.pool.put(n)
+}
+
+// stats returns the number of nodes in the list, and the most it will hold.
+func (f *FreeList) stats() (free, size int) {
+	return f.pool.stats()
 }
 
 // ItemIterator allows callers of Ascend* to iterate in-order over portions of
@@ -135,6 +138,25 @@ func NewWithFreeList(degree int, f *FreeList) *BTree {
 	}
 }
 
+// NewMulti creates a new B-Tree with the given degree that can hold multiple
+// equivalent items, like a multiset.
+//
+// Equivalent items are kept in the order they were inserted with
+// InsertNoReplace.  Where a single item is looked up, replaced or removed by
+// key (Get, ReplaceOrInsert, Delete), the first of the equivalent items is
+// used.
+func NewMulti(degree int) *BTree {
+	return NewMultiWithFreeList(degree, NewFreeList(DefaultFreeListSize))
+}
+
+// NewMultiWithFreeList creates a new multiset B-Tree that uses the given node
+// free list.
+func NewMultiWithFreeList(degree int, f *FreeList) *BTree {
+	t := NewWithFreeList(degree, f)
+	t.multi = true
+	return t
+}
+
 // items stores items in a node.
 type items []Item
 
@@ -181,15 +203,29 @@ func (s *items) truncate(index int) {
 // list.  'found' is true if the item already exists in the list at the given
 // index.
 func (s items) find(item Item) (index int, found bool) {
-	i := sort.Search(len(s), func(i int) bool {
-		return item.Less(s[i])
-	})
+	i := s.upperBound(item)
 	if i > 0 && !s[i-1].Less(item) {
 		return i - 1, true
 	}
 	return i, false
 }
 
+// lowerBound returns the index of the first item in the list that is not less
+// than key.
+func (s items) lowerBound(key Item) int {
+	return sort.Search(len(s), func(i int) bool {
+		return !s[i].Less(key)
+	})
+}
+
+// upperBound returns the index of the first item in the list that is greater
+// than key.
+func (s items) upperBound(key Item) int {
+	return sort.Search(len(s), func(i int) bool {
+		return key.Less(s[i])
+	})
+}
+
 // children stores child nodes in a node.
 type children []*node
 
@@ -241,6 +277,11 @@ type node struct {
 	items    items
 	children children
 	cow      *copyOnWriteContext
+	// size is the number of items in the subtree rooted at this node.
+	size int
+	// agg caches the aggregate of the subtree under the tree's Monoid, if it
+	// has one.
+	agg interface{}
 }
 
 func (n *node) mutableFor(cow *copyOnWriteContext) *node {
@@ -261,6 +302,8 @@ func (n *node) mutableFor(cow *copyOnWriteContext) *node {
 		out.children = make(children, len(n.children), cap(n.children))
 	}
 	copy(out.children, n.children)
+	out.size = n.size
+	out.agg = n.agg
 	return out
 }
 
@@ -282,9 +325,44 @@ func (n *node) split(i int) (Item, *node) {
 		next.children = append(next.children, n.children[i+1:]...)
 		n.children.truncate(i + 1)
 	}
+	next.recount()
+	n.size -= next.size + 1
+	n.reaggregate()
 	return item, next
 }
 
+// recount recomputes the size and aggregate of this node from its items and
+// its children.
+func (n *node) recount() {
+	n.size = len(n.items)
+	for _, c := range n.children {
+		n.size += c.size
+	}
+	n.reaggregate()
+}
+
+// reaggregate recomputes the aggregate of this node from its items and the
+// aggregates of its children, if its tree has a Monoid.  It must be called
+// whenever the node's items change, or its children or their aggregates do,
+// and like size, it is kept up to date bottom-up.
+func (n *node) reaggregate() {
+	m := n.cow.monoid
+	if m == nil {
+		return
+	}
+	acc := m.Identity
+	for i, item := range n.items {
+		if len(n.children) > 0 {
+			acc = m.Combine(acc, n.children[i].agg)
+		}
+		acc = m.Combine(acc, m.Measure(item))
+	}
+	if len(n.children) > 0 {
+		acc = m.Combine(acc, n.children[len(n.items)].agg)
+	}
+	n.agg = acc
+}
+
 // maybeSplitChild checks if a child should be split, and if so splits it.
 // Returns whether or not a split occurred.
 func (n *node) maybeSplitChild(i, maxItems int) bool {
@@ -300,16 +378,26 @@ func (n *node) maybeSplitChild(i, maxItems int) bool {
 
 // insert inserts an item into the subtree rooted at this node, making sure
 // no nodes in the subtree exceed maxItems items.  Should an equivalent item be
-// be found/replaced by insert, it will be returned.
-func (n *node) insert(item Item, maxItems int) Item {
-	i, found := n.items.find(item)
+// be found/replaced by insert, it will be returned.  If dup is true, item is
+// instead always inserted, after any equivalent items.
+func (n *node) insert(item Item, maxItems int, dup bool) Item {
+	var i int
+	var found bool
+	if dup {
+		i = n.items.upperBound(item)
+	} else {
+		i, found = n.items.find(item)
+	}
 	if found {
 		out := n.items[i]
 		n.items[i] = item
+		n.reaggregate()
 		return out
 	}
 	if len(n.children) == 0 {
 		n.items.insertAt(i, item)
+		n.size++
+		n.reaggregate()
 		return nil
 	}
 	if n.maybeSplitChild(i, maxItems) {
@@ -317,15 +405,21 @@ func (n *node) insert(item Item, maxItems int) Item {
 		switch {
 		case item.Less(inTree):
 			// no change, we want first split node
-		case inTree.Less(item):
+		case dup || inTree.Less(item):
 			i++ // we want second split node
 		default:
 			out := n.items[i]
 			n.items[i] = item
+			n.reaggregate()
 			return out
 		}
 	}
-	return n.mutableChild(i).insert(item, maxItems)
+	out := n.mutableChild(i).insert(item, maxItems, dup)
+	if out == nil {
+		n.size++
+	}
+	n.reaggregate()
+	return out
 }
 
 // get finds the given key in the subtree and returns it.
@@ -339,6 +433,99 @@ func (n *node) get(key Item) Item {
 	return nil
 }
 
+// getFirst finds the first of the items equal to key in the subtree and
+// returns it.
+func (n *node) getFirst(key Item) Item {
+	i := n.items.lowerBound(key)
+	if len(n.children) > 0 {
+		if out := n.children[i].getFirst(key); out != nil {
+			return out
+		}
+	}
+	if i < len(n.items) && !key.Less(n.items[i]) {
+		return n.items[i]
+	}
+	return nil
+}
+
+// getAt returns the item at the given position of the subtree's sorted order.
+// index must be in the range [0, n.size).
+func (n *node) getAt(index int) Item {
+	if len(n.children) == 0 {
+		return n.items[index]
+	}
+	for i, c := range n.children {
+		if index < c.size {
+			return c.getAt(index)
+		}
+		index -= c.size
+		if index == 0 {
+			return n.items[i]
+		}
+		index--
+	}
+	panic("index out of range")
+}
+
+// rank returns the number of items in the subtree that are less than key, and
+// whether an item equal to key is in the subtree.
+func (n *node) rank(key Item) (int, bool) {
+	i := n.items.lowerBound(key)
+	found := i < len(n.items) && !key.Less(n.items[i])
+	r := i
+	if len(n.children) == 0 {
+		return r, found
+	}
+	for _, c := range n.children[:i] {
+		r += c.size
+	}
+	// Equivalent items may also sit in the child to the left of items[i], so
+	// we keep descending even when found.
+	cr, childFound := n.children[i].rank(key)
+	return r + cr, found || childFound
+}
+
+// rankAfter returns the number of items in the subtree that are less than or
+// equal to key.
+func (n *node) rankAfter(key Item) int {
+	i := n.items.upperBound(key)
+	r := i
+	if len(n.children) == 0 {
+		return r
+	}
+	for _, c := range n.children[:i] {
+		r += c.size
+	}
+	return r + n.children[i].rankAfter(key)
+}
+
+// replaceAt replaces the item at the given position of the subtree's sorted
+// order, returning the old item.  index must be in the range [0, n.size).
+func (n *node) replaceAt(index int, item Item) Item {
+	if len(n.children) == 0 {
+		out := n.items[index]
+		n.items[index] = item
+		n.reaggregate()
+		return out
+	}
+	for i, c := range n.children {
+		if index < c.size {
+			out := n.mutableChild(i).replaceAt(index, item)
+			n.reaggregate()
+			return out
+		}
+		index -= c.size
+		if index == 0 {
+			out := n.items[i]
+			n.items[i] = item
+			n.reaggregate()
+			return out
+		}
+		index--
+	}
+	panic("index out of range")
+}
+
 // min returns the first item in the subtree.
 func min(n *node) Item {
 	if n == nil {
@@ -383,19 +570,28 @@ func (n *node) remove(item Item, minItems int, typ toRemove) Item {
 	switch typ {
 	case removeMax:
 		if len(n.children) == 0 {
-			return n.items.pop()
+			n.size--
+			out := n.items.pop()
+			n.reaggregate()
+			return out
 		}
 		i = len(n.items)
 	case removeMin:
 		if len(n.children) == 0 {
-			return n.items.removeAt(0)
+			n.size--
+			out := n.items.removeAt(0)
+			n.reaggregate()
+			return out
 		}
 		i = 0
 	case removeItem:
 		i, found = n.items.find(item)
 		if len(n.children) == 0 {
 			if found {
-				return n.items.removeAt(i)
+				n.size--
+				out := n.items.removeAt(i)
+				n.reaggregate()
+				return out
 			}
 			return nil
 		}
@@ -418,11 +614,58 @@ func (n *node) remove(item Item, minItems int, typ toRemove) Item {
 		// predecessor of item i (the rightmost leaf of our immediate left child)
 		// and set it into where we pulled the item from.
 		n.items[i] = child.remove(nil, minItems, removeMax)
+		n.size--
+		n.reaggregate()
 		return out
 	}
 	// Final recursive call.  Once we're here, we know that the item isn't in this
 	// node and that the child is big enough to remove from.
-	return child.remove(item, minItems, typ)
+	out := child.remove(item, minItems, typ)
+	if out != nil {
+		n.size--
+		n.reaggregate()
+	}
+	return out
+}
+
+// removeIndex removes the item at the given position of the subtree's sorted
+// order.  index must be in the range [0, n.size).
+func (n *node) removeIndex(index int, minItems int) Item {
+	if len(n.children) == 0 {
+		n.size--
+		out := n.items.removeAt(index)
+		n.reaggregate()
+		return out
+	}
+	// Find the child holding the position, or the item sitting at it.
+	i, rel, found := 0, index, false
+	for ; i < len(n.items); i++ {
+		if rel < n.children[i].size {
+			break
+		}
+		rel -= n.children[i].size
+		if rel == 0 {
+			found = true
+			break
+		}
+		rel--
+	}
+	if len(n.children[i].items) <= minItems {
+		n.growChild(i, minItems)
+		return n.removeIndex(index, minItems)
+	}
+	child := n.mutableChild(i)
+	n.size--
+	if found {
+		// As in remove, replace the item with its predecessor.
+		out := n.items[i]
+		n.items[i] = child.remove(nil, minItems, removeMax)
+		n.reaggregate()
+		return out
+	}
+	out := child.removeIndex(rel, minItems)
+	n.reaggregate()
+	return out
 }
 
 // growChildAndRemove grows child 'i' to make sure it's possible to remove an
@@ -445,6 +688,14 @@ func (n *node) remove(item Item, minItems int, typ toRemove) Item {
 // whether we're in case 1 or 2), we'll have enough items and can guarantee
 // that we hit case A.
 func (n *node) growChildAndRemove(i int, item Item, minItems int, typ toRemove) Item {
+	n.growChild(i, minItems)
+	return n.remove(item, minItems, typ)
+}
+
+// growChild makes sure child 'i' has more than minItems items, by stealing an
+// item from one of its siblings or by merging it with one.  The size of n is
+// unchanged, though the sizes of the children involved are updated.
+func (n *node) growChild(i int, minItems int) {
 	if i > 0 && len(n.children[i-1].items) > minItems {
 		// Steal from left child
 		child := n.mutableChild(i)
@@ -452,9 +703,16 @@ func (n *node) growChildAndRemove(i int, item Item, minItems int, typ toRemove)
 		stolenItem := stealFrom.items.pop()
 		child.items.insertAt(0, n.items[i-1])
 		n.items[i-1] = stolenItem
+		child.size++
+		stealFrom.size--
 		if len(stealFrom.children) > 0 {
-			child.children.insertAt(0, stealFrom.children.pop())
+			stolenChild := stealFrom.children.pop()
+			child.children.insertAt(0, stolenChild)
+			child.size += stolenChild.size
+			stealFrom.size -= stolenChild.size
 		}
+		child.reaggregate()
+		stealFrom.reaggregate()
 	} else if i < len(n.items) && len(n.children[i+1].items) > minItems {
 		// steal from right child
 		child := n.mutableChild(i)
@@ -462,9 +720,16 @@ func (n *node) growChildAndRemove(i int, item Item, minItems int, typ toRemove)
 		stolenItem := stealFrom.items.removeAt(0)
 		child.items = append(child.items, n.items[i])
 		n.items[i] = stolenItem
+		child.size++
+		stealFrom.size--
 		if len(stealFrom.children) > 0 {
-			child.children = append(child.children, stealFrom.children.removeAt(0))
+			stolenChild := stealFrom.children.removeAt(0)
+			child.children = append(child.children, stolenChild)
+			child.size += stolenChild.size
+			stealFrom.size -= stolenChild.size
 		}
+		child.reaggregate()
+		stealFrom.reaggregate()
 	} else {
 		if i >= len(n.items) {
 			i--
@@ -476,9 +741,251 @@ func (n *node) growChildAndRemove(i int, item Item, minItems int, typ toRemove)
 		child.items = append(child.items, mergeItem)
 		child.items = append(child.items, mergeChild.items...)
 		child.children = append(child.children, mergeChild.children...)
+		child.size += mergeChild.size + 1
+		child.reaggregate()
 		n.cow.freeNode(mergeChild)
 	}
-	return n.remove(item, minItems, typ)
+}
+
+// rebalance evens out the items of children i and i+1 so that both hold at
+// least minItems items, given that at least one of them already does, or
+// merges them into child i if all their items fit in a single node.  The size
+// of n is unchanged.
+func (n *node) rebalance(i, maxItems int) {
+	if len(n.children[i].items)+1+len(n.children[i+1].items) <= maxItems {
+		left, right := n.mutableChild(i), n.children[i+1]
+		left.items = append(left.items, n.items.removeAt(i))
+		left.items = append(left.items, right.items...)
+		left.children = append(left.children, right.children...)
+		left.size += right.size + 1
+		left.reaggregate()
+		n.children.removeAt(i + 1)
+		n.cow.freeNode(right)
+		return
+	}
+	left, right := n.mutableChild(i), n.mutableChild(i+1)
+	all := make(items, 0, len(left.items)+1+len(right.items))
+	all = append(all, left.items...)
+	all = append(all, n.items[i])
+	all = append(all, right.items...)
+	kids := make(children, 0, len(left.children)+len(right.children))
+	kids = append(kids, left.children...)
+	kids = append(kids, right.children...)
+	// Split the combined items evenly; since they didn't fit in one node,
+	// both halves get at least minItems.
+	mid := len(all) / 2
+	left.items.truncate(0)
+	left.items = append(left.items, all[:mid]...)
+	n.items[i] = all[mid]
+	right.items.truncate(0)
+	right.items = append(right.items, all[mid+1:]...)
+	if len(kids) > 0 {
+		left.children.truncate(0)
+		left.children = append(left.children, kids[:mid+1]...)
+		right.children.truncate(0)
+		right.children = append(right.children, kids[mid+1:]...)
+	}
+	left.recount()
+	right.recount()
+}
+
+// height returns the number of levels in the subtree rooted at n, or 0 if n is
+// nil.
+func (n *node) height() int {
+	h := 0
+	for ; n != nil; h++ {
+		if len(n.children) == 0 {
+			return h + 1
+		}
+		n = n.children[0]
+	}
+	return h
+}
+
+// collapse returns the subtree n of height h with any empty root removed,
+// along with its new height.  An empty leaf becomes nil, of height 0.
+func (c *copyOnWriteContext) collapse(n *node, h int) (*node, int) {
+	for n != nil && len(n.items) == 0 {
+		old := n
+		if len(n.children) > 0 {
+			n = n.children[0]
+		} else {
+			n = nil
+		}
+		h--
+		c.freeNode(old)
+	}
+	return n, h
+}
+
+// splitAt splits the subtree rooted at n, of height h, into one subtree
+// holding the items less than key and one holding the rest, and returns both
+// along with their heights.  Either part may be nil, and the root of either
+// may hold fewer than minItems items; every other node is left valid.
+//
+// The nodes of n are reused for the parts, after being made mutable for c.
+func (c *copyOnWriteContext) splitAt(n *node, h int, key Item, maxItems int) (l *node, lh int, r *node, rh int) {
+	n = n.mutableFor(c)
+	i := n.items.lowerBound(key)
+	right := c.newNode()
+	if len(n.children) == 0 {
+		right.items = append(right.items, n.items[i:]...)
+		n.items.truncate(i)
+		right.recount()
+		n.recount()
+		l, lh = c.collapse(n, 1)
+		r, rh = c.collapse(right, 1)
+		return
+	}
+	cl, clh, cr, crh := c.splitAt(n.children[i], h-1, key, maxItems)
+	// The right part is cr, followed by items[i] and everything after it.
+	r, rh = cr, crh
+	if i < len(n.items) {
+		sep := n.items[i]
+		right.items = append(right.items, n.items[i+1:]...)
+		right.children = append(right.children, n.children[i+1:]...)
+		right.recount()
+		suffix, suffixh := c.collapse(right, h)
+		r, rh = c.join(cr, crh, sep, suffix, suffixh, maxItems)
+	} else {
+		c.freeNode(right)
+	}
+	// The left part is everything before items[i-1], then items[i-1] and cl.
+	l, lh = cl, clh
+	if i > 0 {
+		sep := n.items[i-1]
+		n.items.truncate(i - 1)
+		n.children.truncate(i)
+		n.recount()
+		prefix, prefixh := c.collapse(n, h)
+		l, lh = c.join(prefix, prefixh, sep, cl, clh, maxItems)
+	} else {
+		c.freeNode(n)
+	}
+	return
+}
+
+// adopt makes c the owner of the nodes in the subtree at n owned by from.  A
+// node from doesn't own can't have children it owns, so only those nodes are
+// descended into.
+func (c *copyOnWriteContext) adopt(n *node, from *copyOnWriteContext) {
+	if n.cow != from {
+		return
+	}
+	n.cow = c
+	for _, child := range n.children {
+		c.adopt(child, from)
+	}
+}
+
+// join returns a subtree, and its height, holding the items of l, then sep,
+// then the items of r.  Every item in l must be less than sep, which must be
+// less than every item in r.  l and r may be nil, and their roots may hold
+// fewer than minItems items.  Their nodes are reused, after being made mutable
+// for c where they need to change.
+func (c *copyOnWriteContext) join(l *node, lh int, sep Item, r *node, rh int, maxItems int) (*node, int) {
+	var item Item
+	var next *node
+	switch {
+	case lh > rh:
+		l = l.mutableFor(c)
+		item, next = l.joinRight(lh, sep, r, rh, maxItems)
+	case lh < rh:
+		r = r.mutableFor(c)
+		item, next = r.joinLeft(rh, l, lh, sep, maxItems)
+		// joinLeft leaves the left half in r.
+		l, lh = r, rh
+	case l == nil:
+		n := c.newNode()
+		n.items = append(n.items, sep)
+		n.recount()
+		return n, 1
+	case len(l.items)+1+len(r.items) <= maxItems:
+		l = l.mutableFor(c)
+		l.items = append(l.items, sep)
+		l.items = append(l.items, r.items...)
+		l.children = append(l.children, r.children...)
+		l.size += r.size + 1
+		l.reaggregate()
+		c.freeNode(r)
+		return l, lh
+	default:
+		item, next = sep, r
+	}
+	if next == nil {
+		return l, lh
+	}
+	root := c.newNode()
+	root.items = append(root.items, item)
+	root.children = append(root.children, l, next)
+	root.recount()
+	// With equal heights, either root may have been underfull, but together
+	// they hold too many items for one node, so rebalance won't merge them.
+	if len(l.items) < maxItems/2 || len(next.items) < maxItems/2 {
+		root.rebalance(0, maxItems)
+	}
+	return root, lh + 1
+}
+
+// joinRight appends sep and the subtree r, of height rh, to the right edge of
+// the subtree rooted at n, of height h > rh.  n must be mutable.  Should n grow
+// too large, it is split, and the item and node to insert after it in its
+// parent are returned.
+func (n *node) joinRight(h int, sep Item, r *node, rh int, maxItems int) (Item, *node) {
+	n.size++
+	if r != nil {
+		n.size += r.size
+	}
+	if h == rh+1 {
+		n.items = append(n.items, sep)
+		if r != nil {
+			n.children = append(n.children, r)
+			if len(r.items) < maxItems/2 {
+				n.rebalance(len(n.items)-1, maxItems)
+			}
+		}
+	} else {
+		child := n.mutableChild(len(n.children) - 1)
+		if item, next := child.joinRight(h-1, sep, r, rh, maxItems); next != nil {
+			n.items = append(n.items, item)
+			n.children = append(n.children, next)
+		}
+	}
+	n.reaggregate()
+	if len(n.items) > maxItems {
+		return n.split(len(n.items) / 2)
+	}
+	return nil, nil
+}
+
+// joinLeft is the mirror image of joinRight: it prepends the subtree l, of
+// height lh, and sep to the left edge of the subtree rooted at n, of height
+// h > lh.
+func (n *node) joinLeft(h int, l *node, lh int, sep Item, maxItems int) (Item, *node) {
+	n.size++
+	if l != nil {
+		n.size += l.size
+	}
+	if h == lh+1 {
+		n.items.insertAt(0, sep)
+		if l != nil {
+			n.children.insertAt(0, l)
+			if len(l.items) < maxItems/2 {
+				n.rebalance(0, maxItems)
+			}
+		}
+	} else {
+		child := n.mutableChild(0)
+		if item, next := child.joinLeft(h-1, l, lh, sep, maxItems); next != nil {
+			n.items.insertAt(0, item)
+			n.children.insertAt(1, next)
+		}
+	}
+	n.reaggregate()
+	if len(n.items) > maxItems {
+		return n.split(len(n.items) / 2)
+	}
+	return nil, nil
 }
 
 type direction int
@@ -528,7 +1035,7 @@ func (n *node) iterate(dir direction, start, stop Item, includeStart bool, hit b
 	case descend:
 		for i := len(n.items) - 1; i >= 0; i-- {
 			if start != nil && !n.items[i].Less(start) {
-				if !includeStart || hit || start.Less(n.items[i]) {
+				if !includeStart || start.Less(n.items[i]) {
 					continue
 				}
 			}
@@ -574,6 +1081,11 @@ type BTree struct {
 	length int
 	root   *node
 	cow    *copyOnWriteContext
+	// multi is true if the tree may hold multiple equivalent items.
+	multi bool
+	// mods counts the writes that have changed the tree, so that Txn.Commit
+	// can tell whether any were made while the transaction ran.
+	mods uint64
 }
 
 // copyOnWriteContext pointers determine node ownership... a tree with a write
@@ -592,6 +1104,11 @@ type BTree struct {
 // copy.
 type copyOnWriteContext struct {
 	freelist *FreeList
+	// monoid, if set, is used to aggregate the items of each node.
+	monoid *Monoid
+	// gen is the MVCCTree version whose nodes are written with the context.
+	// Other trees leave it zero.
+	gen uint64
 }
 
 // Clone clones the btree, lazily.  Clone should not be called concurrently,
@@ -635,13 +1152,33 @@ func (c *copyOnWriteContext) newNode() (n *node) {
 	return
 }
 
-func (c *copyOnWriteContext) freeNode(n *node) {
+// freeType is the result of calling freeNode.
+type freeType int
+
+const (
+	ftFreelistFull freeType = iota // node was freed (available for GC, not stored in freelist)
+	ftStored                       // node was stored in the freelist for later use
+	ftNotOwned                     // node was ignored by COW, since it's owned by another one
+)
+
+// freeNode frees a node within a given COW context, if it's owned by that
+// context.  It returns what happened to the node (see freeType const
+// documentation).
+func (c *copyOnWriteContext) freeNode(n *node) freeType {
 	if n.cow == c {
 		// clear to allow GC
 		n.items.truncate(0)
 		n.children.truncate(0)
 		n.cow = nil
-		c.freelist.freeNode(n)
+		n.size = 0
+		n.agg = nil
+		if c.freelist.freeNode(n) {
+			return ftStored
+		} else {
+			return ftFreelistFull
+		}
+	} else {
+		return ftNotOwned
 	}
 }
 
@@ -654,34 +1191,98 @@ func (t *BTree) ReplaceOrInsert(item Item) Item {
 	if item == nil {
 		panic("nil item being added to BTree")
 	}
+	if t.multi {
+		// Replace the first of the equivalent items, wherever it may be.
+		if i, found := t.Rank(item); found {
+			t.root = t.root.mutableFor(t.cow)
+			t.mods++
+			return t.root.replaceAt(i, item)
+		}
+	}
+	return t.insert(item, false)
+}
+
+// InsertNoReplace adds the given item to the tree.  If the tree was created
+// by NewMulti, the item is always added, after any equivalent items already in
+// the tree.  Otherwise it is only added if the tree holds no equivalent item.
+//
+// nil cannot be added to the tree (will panic).
+func (t *BTree) InsertNoReplace(item Item) {
+	if item == nil {
+		panic("nil item being added to BTree")
+	}
+	if !t.multi {
+		if t.Get(item) == nil {
+			t.insert(item, false)
+		}
+		return
+	}
+	t.insert(item, true)
+}
+
+// insert adds item to the tree, splitting the root if needed.  See node.insert
+// for the meaning of dup and of the returned item.
+func (t *BTree) insert(item Item, dup bool) Item {
 	if t.root == nil {
 		t.root = t.cow.newNode()
 		t.root.items = append(t.root.items, item)
+		t.root.recount()
 		t.length++
+		t.mods++
 		return nil
-	} else {
-		t.root = t.root.mutableFor(t.cow)
-		if len(t.root.items) >= t.maxItems() {
-			item2, second := t.root.split(t.maxItems() / 2)
-			oldroot := t.root
-			t.root = t.cow.newNode()
-			t.root.items = append(t.root.items, item2)
-			t.root.children = append(t.root.children, oldroot, second)
-		}
 	}
-	out := t.root.insert(item, t.maxItems())
+	t.splitRoot()
+	out := t.root.insert(item, t.maxItems(), dup)
 	if out == nil {
 		t.length++
 	}
+	t.mods++
 	return out
 }
 
+// splitRoot makes the root mutable and, if it is full, splits it under a new
+// root, so that there is room to insert below it.
+func (t *BTree) splitRoot() {
+	t.root = t.root.mutableFor(t.cow)
+	if len(t.root.items) >= t.maxItems() {
+		item2, second := t.root.split(t.maxItems() / 2)
+		oldroot := t.root
+		t.root = t.cow.newNode()
+		t.root.items = append(t.root.items, item2)
+		t.root.children = append(t.root.children, oldroot, second)
+		t.root.recount()
+	}
+}
+
 // Delete removes an item equal to the passed in item from the tree, returning
 // it.  If no such item exists, returns nil.
 func (t *BTree) Delete(item Item) Item {
+	if t.multi {
+		if i, found := t.Rank(item); found {
+			return t.DeleteAt(i)
+		}
+		return nil
+	}
 	return t.deleteItem(item, removeItem)
 }
 
+// DeleteAll removes every item equal to key from the tree, returning the
+// number of items removed.
+func (t *BTree) DeleteAll(key Item) int {
+	if t.root == nil {
+		return 0
+	}
+	i, found := t.Rank(key)
+	if !found {
+		return 0
+	}
+	count := t.root.rankAfter(key) - i
+	for j := 0; j < count; j++ {
+		t.DeleteAt(i)
+	}
+	return count
+}
+
 // DeleteMin removes the smallest item in the tree and returns it.
 // If no such item exists, returns nil.
 func (t *BTree) DeleteMin() Item {
@@ -707,7 +1308,26 @@ func (t *BTree) deleteItem(item Item, typ toRemove) Item {
 	}
 	if out != nil {
 		t.length--
+		t.mods++
+	}
+	return out
+}
+
+// DeleteAt removes the item at the given index of the tree's sorted order and
+// returns it.  If index is out of range, returns nil.
+func (t *BTree) DeleteAt(index int) Item {
+	if index < 0 || index >= t.length {
+		return nil
 	}
+	t.root = t.root.mutableFor(t.cow)
+	out := t.root.removeIndex(index, t.minItems())
+	if len(t.root.items) == 0 && len(t.root.children) > 0 {
+		oldroot := t.root
+		t.root = t.root.children[0]
+		t.cow.freeNode(oldroot)
+	}
+	t.length--
+	t.mods++
 	return out
 }
 
@@ -789,9 +1409,25 @@ func (t *BTree) Get(key Item) Item {
 	if t.root == nil {
 		return nil
 	}
+	if t.multi {
+		return t.root.getFirst(key)
+	}
 	return t.root.get(key)
 }
 
+// Count returns the number of items in the tree equal to key.  Unless the tree
+// was created by NewMulti, this is either 0 or 1.
+func (t *BTree) Count(key Item) int {
+	if t.root == nil {
+		return 0
+	}
+	lo, found := t.root.rank(key)
+	if !found {
+		return 0
+	}
+	return t.root.rankAfter(key) - lo
+}
+
 // Min returns the smallest item in the tree, or nil if the tree is empty.
 func (t *BTree) Min() Item {
 	return min(t.root)
@@ -802,16 +1438,267 @@ func (t *BTree) Max() Item {
 	return max(t.root)
 }
 
+// Floor returns the largest item in the tree less than or equal to key, or nil
+// if there is none.  In a multiset tree, it returns the last of the equal
+// items.
+func (t *BTree) Floor(key Item) Item {
+	return below(t.root, key, true)
+}
+
+// Lower returns the largest item in the tree strictly less than key, or nil if
+// there is none.
+func (t *BTree) Lower(key Item) Item {
+	return below(t.root, key, false)
+}
+
+// Ceiling returns the smallest item in the tree greater than or equal to key,
+// or nil if there is none.  In a multiset tree, it returns the first of the
+// equal items, the same one Get returns.
+func (t *BTree) Ceiling(key Item) Item {
+	return above(t.root, key, true)
+}
+
+// Higher returns the smallest item in the tree strictly greater than key, or
+// nil if there is none.
+func (t *BTree) Higher(key Item) Item {
+	return above(t.root, key, false)
+}
+
+// below returns the last item in the subtree less than key, or equal to it
+// if inclusive.  In each node, the best candidate is the last item before the
+// search position, and any better one is in the child that follows it, so a
+// single descent remembering the latest candidate suffices.
+func below(n *node, key Item, inclusive bool) (out Item) {
+	for n != nil {
+		var i int
+		if inclusive {
+			i = n.items.upperBound(key)
+		} else {
+			i = n.items.lowerBound(key)
+		}
+		if i > 0 {
+			out = n.items[i-1]
+		}
+		if len(n.children) == 0 {
+			break
+		}
+		n = n.children[i]
+	}
+	return out
+}
+
+// above is the mirror image of below: it returns the first item in the
+// subtree greater than key, or equal to it if inclusive.
+func above(n *node, key Item, inclusive bool) (out Item) {
+	for n != nil {
+		var i int
+		if inclusive {
+			i = n.items.lowerBound(key)
+		} else {
+			i = n.items.upperBound(key)
+		}
+		if i < len(n.items) {
+			out = n.items[i]
+		}
+		if len(n.children) == 0 {
+			break
+		}
+		n = n.children[i]
+	}
+	return out
+}
+
+// GetAt returns the item at the given index of the tree's sorted order, so
+// that GetAt(0) is the minimum.  It returns nil if index is out of range.
+func (t *BTree) GetAt(index int) Item {
+	if index < 0 || index >= t.length {
+		return nil
+	}
+	return t.root.getAt(index)
+}
+
+// Rank returns the number of items in the tree that are less than key, which
+// is the index key has (or would have) in the tree's sorted order.  The second
+// return value reports whether key is in the tree.
+func (t *BTree) Rank(key Item) (int, bool) {
+	if t.root == nil {
+		return 0, false
+	}
+	return t.root.rank(key)
+}
+
 // Has returns true if the given key is in the tree.
 func (t *BTree) Has(key Item) bool {
 	return t.Get(key) != nil
 }
 
+// DeleteRange removes every item in the range [greaterOrEqual, lessThan) from
+// the tree, returning the number of items removed.
+//
+// Rather than removing the items one at a time, DeleteRange cuts the tree
+// along the two boundaries of the range and joins what's left, so only the
+// nodes along those boundaries are touched; subtrees lying entirely inside
+// the range are detached whole, and those owned by this tree are added to its
+// freelist.
+//
+// A nil greaterOrEqual or lessThan leaves the range open at that end, as in a
+// Range, so DeleteRange(nil, nil) removes every item.
+func (t *BTree) DeleteRange(greaterOrEqual, lessThan Item) int {
+	if t.root == nil || (greaterOrEqual != nil && lessThan != nil && !greaterOrEqual.Less(lessThan)) {
+		return 0
+	}
+	maxItems := t.maxItems()
+	var l, mid, r *node
+	var lh, rh int
+	rest, resth := t.root, t.root.height()
+	if greaterOrEqual != nil {
+		l, lh, rest, resth = t.cow.splitAt(rest, resth, greaterOrEqual, maxItems)
+	}
+	if rest != nil {
+		if lessThan != nil {
+			mid, _, r, rh = t.cow.splitAt(rest, resth, lessThan, maxItems)
+		} else {
+			mid = rest
+		}
+	}
+	t.root, _ = t.cow.concat(l, lh, r, rh, t.minItems(), maxItems)
+	if mid == nil {
+		return 0
+	}
+	removed := mid.size
+	t.length -= removed
+	t.mods++
+	mid.reset(t.cow)
+	return removed
+}
+
+// SplitAt returns two new trees, one holding the items of t that are less
+// than pivot and one holding the rest.
+//
+// Like Clone, SplitAt leaves the items of t unchanged and lazily shares its
+// nodes with the new trees; only the O(log n) nodes along the path to pivot
+// are copied.  Also like Clone, it marks t's nodes read-only by giving t a new
+// copy-on-write context, so it counts as a write to t: it must not run
+// concurrently with any other use of t.
+func (t *BTree) SplitAt(pivot Item) (left, right *BTree) {
+	left, right = t.Clone(), t.Clone()
+	left.root, left.length = nil, 0
+	right.root, right.length = nil, 0
+	if t.root == nil {
+		return
+	}
+	l, _, r, _ := left.cow.splitAt(t.root, t.root.height(), pivot, t.maxItems())
+	if l != nil {
+		left.root, left.length = l, l.size
+	}
+	if r != nil {
+		// The nodes splitAt built for the right part belong with right.
+		right.cow.adopt(r, left.cow)
+		right.root, right.length = r, r.size
+	}
+	return
+}
+
+// Join returns a new tree holding the items of left followed by those of
+// right.  Every item in left must be less than every item in right (or, for
+// trees created by NewMulti, no greater), and both trees must have the same
+// degree and Monoid; otherwise Join panics.  The new tree uses left's freelist.
+//
+// Like Clone, Join leaves the items of both trees unchanged and lazily shares
+// their nodes with the new tree; only the O(log n) nodes along the seam are
+// copied.  Also like Clone, it marks their nodes read-only by giving left and
+// right new copy-on-write contexts, so it counts as a write to both: it must
+// not run concurrently with any other use of left or right.
+func Join(left, right *BTree) *BTree {
+	if left.degree != right.degree {
+		panic("joining trees of different degrees")
+	}
+	if left.cow.monoid != right.cow.monoid {
+		panic("joining trees with different monoids")
+	}
+	multi := left.multi || right.multi
+	if left.Len() > 0 && right.Len() > 0 {
+		if lmax, rmin := left.Max(), right.Min(); rmin.Less(lmax) || !multi && !lmax.Less(rmin) {
+			panic("joining trees whose items overlap")
+		}
+	}
+	out := left.Clone()
+	out.multi = multi
+	// Mark right's nodes read-only too, the same way Clone does.
+	cow := *right.cow
+	right.cow = &cow
+	l, lh := out.cow.collapse(left.root, left.root.height())
+	r, rh := out.cow.collapse(right.root, right.root.height())
+	out.root, _ = out.cow.concat(l, lh, r, rh, out.minItems(), out.maxItems())
+	out.length = left.length + right.length
+	return out
+}
+
+// concat is like join, but without an item between l and r.
+func (c *copyOnWriteContext) concat(l *node, lh int, r *node, rh int, minItems, maxItems int) (*node, int) {
+	if r == nil {
+		return l, lh
+	}
+	if l == nil {
+		return r, rh
+	}
+	// Take the smallest item of r to use as the separator.
+	r = r.mutableFor(c)
+	sep := r.remove(nil, minItems, removeMin)
+	r, rh = c.collapse(r, rh)
+	return c.join(l, lh, sep, r, rh, maxItems)
+}
+
 // Len returns the number of items currently in the tree.
 func (t *BTree) Len() int {
 	return t.length
 }
 
+// Clear removes all items from the btree.  If addNodesToFreelist is true,
+// t's nodes are added to its freelist as part of this call, until the freelist
+// is full.  Otherwise, the root node is simply dereferenced and the subtree
+// left to Go's normal GC processes.
+//
+// This can be much faster than calling Delete on all elements, because that
+// requires finding/removing each element in the tree and updating the tree
+// accordingly.  It also is somewhat faster than creating a new tree to replace
+// the old one, because nodes from the old tree are reclaimed into the freelist
+// for use by the new one, instead of being lost to the garbage collector.
+//
+// Nodes still shared with a Clone of t are left untouched, and so is
+// everything beneath them, since a node t doesn't own can't have children t
+// owns.
+//
+// This call takes:
+//   O(1): when addNodesToFreelist is false, this is a single operation.
+//   O(1): when the freelist is already full, it breaks out immediately
+//   O(freelist size):  when the freelist is empty and the nodes are all owned
+//       by this tree, nodes are added to the freelist until full.
+func (t *BTree) Clear(addNodesToFreelist bool) {
+	if t.root != nil {
+		if addNodesToFreelist {
+			t.root.reset(t.cow)
+		}
+		t.mods++
+	}
+	t.root, t.length = nil, 0
+}
+
+// reset returns a subtree to the freelist.  It breaks out immediately if the
+// freelist is full, since the only benefit of iterating is to fill that
+// freelist up.  Returns true if parent reset call should continue.
+func (n *node) reset(c *copyOnWriteContext) bool {
+	if n.cow != c {
+		return true
+	}
+	for _, child := range n.children {
+		if !child.reset(c) {
+			return false
+		}
+	}
+	return c.freeNode(n) != ftFreelistFull
+}
+
 // Int implements the Item interface for integers.
 type Int int
 
@@ -819,3 +1706,11 @@ type Int int
 func (a Int) Less(b Item) bool {
 	return a < b.(Int)
 }
+
+// Bytes implements the Item interface for byte strings.
+type Bytes []byte
+
+// Less returns true if a sorts before b in lexicographic byte order.
+func (a Bytes) Less(b Item) bool {
+	return bytes.Compare(a, b.(Bytes)) < 0
+}
@@ -0,0 +1,263 @@
+// Copyright 2014 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package btree
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// intRange returns an ordered list of ints in the range [0, n).
+func intRange(n int) (out []int) {
+	for i := 0; i < n; i++ {
+		out = append(out, i)
+	}
+	return
+}
+
+// intRangeRev returns a reversed ordered list of ints in the range [0, n).
+func intRangeRev(n int) (out []int) {
+	for i := n - 1; i >= 0; i-- {
+		out = append(out, i)
+	}
+	return
+}
+
+// intAll extracts all items from a tree in order as a slice.
+func intAll(t *BTreeG[int]) (out []int) {
+	t.Ascend(func(a int) bool {
+		out = append(out, a)
+		return true
+	})
+	return
+}
+
+// intAllRev extracts all items from a tree in reverse order as a slice.
+func intAllRev(t *BTreeG[int]) (out []int) {
+	t.Descend(func(a int) bool {
+		out = append(out, a)
+		return true
+	})
+	return
+}
+
+func TestBTreeG(t *testing.T) {
+	tr := NewG(*btreeDegree, Less[int]())
+	const treeSize = 10000
+	for i := 0; i < 10; i++ {
+		if min, ok := tr.Min(); ok {
+			t.Fatalf("empty min, got %+v", min)
+		}
+		if max, ok := tr.Max(); ok {
+			t.Fatalf("empty max, got %+v", max)
+		}
+		for _, item := range rand.Perm(treeSize) {
+			if x, ok := tr.ReplaceOrInsert(item); ok {
+				t.Fatal("insert found item", x)
+			}
+		}
+		for _, item := range rand.Perm(treeSize) {
+			if _, ok := tr.ReplaceOrInsert(item); !ok {
+				t.Fatal("insert didn't find item", item)
+			}
+		}
+		if min, ok := tr.Min(); !ok || min != 0 {
+			t.Fatalf("min: want %+v, got %+v", 0, min)
+		}
+		if max, ok := tr.Max(); !ok || max != treeSize-1 {
+			t.Fatalf("max: want %+v, got %+v", treeSize-1, max)
+		}
+		got := intAll(tr)
+		want := intRange(treeSize)
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("mismatch:\n got: %v\nwant: %v", got, want)
+		}
+
+		gotrev := intAllRev(tr)
+		wantrev := intRangeRev(treeSize)
+		if !reflect.DeepEqual(gotrev, wantrev) {
+			t.Fatalf("mismatch:\n got: %v\nwant: %v", gotrev, wantrev)
+		}
+
+		for _, item := range rand.Perm(treeSize) {
+			if _, ok := tr.Delete(item); !ok {
+				t.Fatalf("didn't find %v", item)
+			}
+		}
+		if got = intAll(tr); len(got) > 0 {
+			t.Fatalf("some left!: %v", got)
+		}
+	}
+}
+
+func ExampleBTreeG() {
+	tr := NewG(*btreeDegree, Less[int]())
+	for i := 0; i < 10; i++ {
+		tr.ReplaceOrInsert(i)
+	}
+	fmt.Println("len:       ", tr.Len())
+	v, ok := tr.Get(3)
+	fmt.Println("get3:      ", v, ok)
+	v, ok = tr.Get(100)
+	fmt.Println("get100:    ", v, ok)
+	v, ok = tr.Delete(4)
+	fmt.Println("del4:      ", v, ok)
+	v, ok = tr.Delete(100)
+	fmt.Println("del100:    ", v, ok)
+	v, ok = tr.ReplaceOrInsert(5)
+	fmt.Println("replace5:  ", v, ok)
+	v, ok = tr.ReplaceOrInsert(100)
+	fmt.Println("replace100:", v, ok)
+	v, ok = tr.Min()
+	fmt.Println("min:       ", v, ok)
+	v, ok = tr.DeleteMin()
+	fmt.Println("delmin:    ", v, ok)
+	v, ok = tr.Max()
+	fmt.Println("max:       ", v, ok)
+	v, ok = tr.DeleteMax()
+	fmt.Println("delmax:    ", v, ok)
+	fmt.Println("len:       ", tr.Len())
+	// Output:
+	// len:        10
+	// get3:       3 true
+	// get100:     0 false
+	// del4:       4 true
+	// del100:     0 false
+	// replace5:   5 true
+	// replace100: 0 false
+	// min:        0 true
+	// delmin:     0 true
+	// max:        100 true
+	// delmax:     100 true
+	// len:        8
+}
+
+func TestAscendRangeG(t *testing.T) {
+	tr := NewG(2, Less[int]())
+	for _, v := range rand.Perm(100) {
+		tr.ReplaceOrInsert(v)
+	}
+	var got []int
+	tr.AscendRange(40, 60, func(a int) bool {
+		got = append(got, a)
+		return true
+	})
+	if want := intRange(100)[40:60]; !reflect.DeepEqual(got, want) {
+		t.Fatalf("ascendrange:\n got: %v\nwant: %v", got, want)
+	}
+	got = got[:0]
+	tr.AscendLessThan(60, func(a int) bool {
+		got = append(got, a)
+		return true
+	})
+	if want := intRange(100)[:60]; !reflect.DeepEqual(got, want) {
+		t.Fatalf("ascendlessthan:\n got: %v\nwant: %v", got, want)
+	}
+	got = got[:0]
+	tr.AscendGreaterOrEqual(40, func(a int) bool {
+		if a > 50 {
+			return false
+		}
+		got = append(got, a)
+		return true
+	})
+	if want := intRange(100)[40:51]; !reflect.DeepEqual(got, want) {
+		t.Fatalf("ascendgreaterorequal:\n got: %v\nwant: %v", got, want)
+	}
+}
+
+func TestDescendRangeG(t *testing.T) {
+	tr := NewG(2, Less[int]())
+	for _, v := range rand.Perm(100) {
+		tr.ReplaceOrInsert(v)
+	}
+	var got []int
+	tr.DescendRange(60, 40, func(a int) bool {
+		got = append(got, a)
+		return true
+	})
+	if want := intRangeRev(100)[39:59]; !reflect.DeepEqual(got, want) {
+		t.Fatalf("descendrange:\n got: %v\nwant: %v", got, want)
+	}
+	got = got[:0]
+	tr.DescendLessOrEqual(40, func(a int) bool {
+		got = append(got, a)
+		return true
+	})
+	if want := intRangeRev(100)[59:]; !reflect.DeepEqual(got, want) {
+		t.Fatalf("descendlessorequal:\n got: %v\nwant: %v", got, want)
+	}
+	got = got[:0]
+	tr.DescendGreaterThan(40, func(a int) bool {
+		if a < 50 {
+			return false
+		}
+		got = append(got, a)
+		return true
+	})
+	if want := intRangeRev(100)[:50]; !reflect.DeepEqual(got, want) {
+		t.Fatalf("descendgreaterthan:\n got: %v\nwant: %v", got, want)
+	}
+}
+
+func TestCloneG(t *testing.T) {
+	tr := NewG(*btreeDegree, Less[int]())
+	for _, v := range rand.Perm(cloneTestSize) {
+		tr.ReplaceOrInsert(v)
+	}
+	tr2 := tr.Clone()
+	for i := 0; i < cloneTestSize/2; i++ {
+		tr.Delete(i)
+	}
+	if got, want := intAll(tr2), intRange(cloneTestSize); !reflect.DeepEqual(got, want) {
+		t.Fatalf("clone changed: got %d items, want %d", len(got), len(want))
+	}
+	if got, want := intAll(tr), intRange(cloneTestSize)[cloneTestSize/2:]; !reflect.DeepEqual(got, want) {
+		t.Fatalf("original: got %d items, want %d", len(got), len(want))
+	}
+}
+
+func BenchmarkInsertG(b *testing.B) {
+	b.StopTimer()
+	insertP := rand.Perm(benchmarkTreeSize)
+	b.StartTimer()
+	i := 0
+	for i < b.N {
+		tr := NewG(*btreeDegree, Less[int]())
+		for _, item := range insertP {
+			tr.ReplaceOrInsert(item)
+			i++
+			if i >= b.N {
+				return
+			}
+		}
+	}
+}
+
+func BenchmarkGetG(b *testing.B) {
+	b.StopTimer()
+	insertP := rand.Perm(benchmarkTreeSize)
+	removeP := rand.Perm(benchmarkTreeSize)
+	tr := NewG(*btreeDegree, Less[int]())
+	for _, v := range insertP {
+		tr.ReplaceOrInsert(v)
+	}
+	b.StartTimer()
+	for i := 0; i < b.N; i++ {
+		tr.Get(removeP[i%benchmarkTreeSize])
+	}
+}
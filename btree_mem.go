@@ -14,7 +14,8 @@
 
 // +build ignore
 
-// This binary compares memory usage between btree and gollrb.
+// This binary compares memory usage between btree and gollrb, and between a
+// BTree of Ints and a BTreeG of int64 keys made by NewFixed.
 package main
 
 import (
@@ -32,6 +33,7 @@ var (
 	size   = flag.Int("size", 1000000, "size of the tree to build")
 	degree = flag.Int("degree", 8, "degree of btree")
 	gollrb = flag.Bool("llrb", false, "use llrb instead of btree")
+	fixed  = flag.Bool("fixed", false, "use btree.NewFixed[int64] instead of btree.New")
 )
 
 func main() {
@@ -53,6 +55,12 @@ func main() {
 			tr.ReplaceOrInsert(llrb.Int(v))
 		}
 		t = tr // keep it around
+	} else if *fixed {
+		tr := btree.NewFixed[int64](*degree)
+		for _, v := range vals {
+			tr.ReplaceOrInsert(int64(v))
+		}
+		t = tr // keep it around
 	} else {
 		tr := btree.New(*degree)
 		for _, v := range vals {
@@ -70,6 +78,10 @@ func main() {
 	fmt.Println("-------- AFTER GC ----------")
 	runtime.ReadMemStats(&stats)
 	fmt.Printf("%+v\n", stats)
+	if tr, ok := t.(*btree.BTree); ok {
+		fmt.Println("-------- STRUCTURE ----------")
+		fmt.Printf("%+v\n", tr.Stats())
+	}
 	if t == v {
 		fmt.Println("to make sure vals and tree aren't GC'd")
 	}
@@ -15,6 +15,7 @@
 package btree
 
 import (
+	"encoding/binary"
 	"flag"
 	"fmt"
 	"math/rand"
@@ -342,6 +343,636 @@ func TestDescendGreaterThan(t *testing.T) {
 	}
 }
 
+// checkSizes verifies that every node's size matches the number of items
+// actually stored beneath it.
+func checkSizes(t *testing.T, n *node) int {
+	if n == nil {
+		return 0
+	}
+	size := len(n.items)
+	for _, c := range n.children {
+		size += checkSizes(t, c)
+	}
+	if n.size != size {
+		t.Fatalf("node %v: size %d, want %d", n.items, n.size, size)
+	}
+	return size
+}
+
+func TestGetAtRank(t *testing.T) {
+	tr := New(3)
+	for _, v := range perm(1000) {
+		tr.ReplaceOrInsert(Int(v.(Int) * 2))
+	}
+	checkSizes(t, tr.root)
+	for i := 0; i < 1000; i++ {
+		if got, want := tr.GetAt(i), Item(Int(i*2)); got != want {
+			t.Fatalf("GetAt(%d): got %v, want %v", i, got, want)
+		}
+		if r, found := tr.Rank(Int(i * 2)); r != i || !found {
+			t.Fatalf("Rank(%d): got %d %v, want %d true", i*2, r, found, i)
+		}
+		if r, found := tr.Rank(Int(i*2 + 1)); r != i+1 || found {
+			t.Fatalf("Rank(%d): got %d %v, want %d false", i*2+1, r, found, i+1)
+		}
+	}
+	if got := tr.GetAt(-1); got != nil {
+		t.Fatalf("GetAt(-1): got %v", got)
+	}
+	if got := tr.GetAt(1000); got != nil {
+		t.Fatalf("GetAt(1000): got %v", got)
+	}
+	if r, found := New(3).Rank(Int(1)); r != 0 || found {
+		t.Fatalf("Rank on empty tree: got %d %v", r, found)
+	}
+}
+
+func TestFloorCeiling(t *testing.T) {
+	tr := New(*btreeDegree)
+	if tr.Floor(Int(1)) != nil || tr.Ceiling(Int(1)) != nil || tr.Lower(Int(1)) != nil || tr.Higher(Int(1)) != nil {
+		t.Fatal("empty tree has neighbors")
+	}
+	// Even numbers only, so odd keys fall between items.
+	for _, v := range perm(100) {
+		tr.ReplaceOrInsert(v.(Int) * 2)
+	}
+	items := all(tr)
+	for k := Int(-2); k <= 200; k++ {
+		// Work out the expected neighbors by scanning every item.
+		var floor, lower, ceil, higher Item
+		for _, item := range items {
+			if !k.Less(item) {
+				floor = item
+			}
+			if item.Less(k) {
+				lower = item
+			}
+			if ceil == nil && !item.Less(k) {
+				ceil = item
+			}
+			if higher == nil && k.Less(item) {
+				higher = item
+			}
+		}
+		for _, test := range []struct {
+			name      string
+			got, want Item
+		}{
+			{"Floor", tr.Floor(k), floor},
+			{"Ceiling", tr.Ceiling(k), ceil},
+			{"Lower", tr.Lower(k), lower},
+			{"Higher", tr.Higher(k), higher},
+		} {
+			if test.got != test.want {
+				t.Fatalf("%s(%v): got %v, want %v", test.name, k, test.got, test.want)
+			}
+		}
+	}
+	key := Item(Int(101))
+	if allocs := testing.AllocsPerRun(100, func() {
+		tr.Floor(key)
+		tr.Ceiling(key)
+		tr.Lower(key)
+		tr.Higher(key)
+	}); allocs != 0 {
+		t.Errorf("lookups allocated %v times", allocs)
+	}
+}
+
+func TestFloorCeilingMulti(t *testing.T) {
+	tr := NewMulti(2)
+	for i := 0; i < 100; i++ {
+		tr.InsertNoReplace(pair{i % 10, i})
+	}
+	if got, want := tr.Floor(pair{key: 5}), (pair{5, 95}); got != want {
+		t.Errorf("Floor: got %v, want %v", got, want)
+	}
+	if got, want := tr.Ceiling(pair{key: 5}), (pair{5, 5}); got != want {
+		t.Errorf("Ceiling: got %v, want %v", got, want)
+	}
+	if got, want := tr.Lower(pair{key: 5}), (pair{4, 94}); got != want {
+		t.Errorf("Lower: got %v, want %v", got, want)
+	}
+	if got, want := tr.Higher(pair{key: 5}), (pair{6, 6}); got != want {
+		t.Errorf("Higher: got %v, want %v", got, want)
+	}
+}
+
+func TestDeleteAt(t *testing.T) {
+	tr := New(3)
+	for _, v := range perm(1000) {
+		tr.ReplaceOrInsert(v)
+	}
+	clone := tr.Clone()
+	want := rang(1000)
+	for len(want) > 0 {
+		i := rand.Intn(len(want))
+		if got := tr.DeleteAt(i); got != want[i] {
+			t.Fatalf("DeleteAt(%d): got %v, want %v", i, got, want[i])
+		}
+		want = append(want[:i], want[i+1:]...)
+		if tr.Len() != len(want) {
+			t.Fatalf("len: got %d, want %d", tr.Len(), len(want))
+		}
+		checkSizes(t, tr.root)
+	}
+	if got := tr.DeleteAt(0); got != nil {
+		t.Fatalf("DeleteAt on empty tree: got %v", got)
+	}
+	checkSizes(t, clone.root)
+	if got := all(clone); !reflect.DeepEqual(got, rang(1000)) {
+		t.Fatalf("clone modified: got %d items", len(got))
+	}
+	for i := 0; i < 1000; i += 7 {
+		clone.Delete(Int(i))
+	}
+	checkSizes(t, clone.root)
+	for i := 0; i < clone.Len(); i++ {
+		if r, _ := clone.Rank(clone.GetAt(i)); r != i {
+			t.Fatalf("Rank(GetAt(%d)): got %d", i, r)
+		}
+	}
+}
+
+// pair is an Item ordered only by key, so that items with equal keys but
+// different values can be told apart.
+type pair struct {
+	key, val int
+}
+
+func (a pair) Less(b Item) bool {
+	return a.key < b.(pair).key
+}
+
+// sameItems is like reflect.DeepEqual, but treats nil and empty as equal.
+func sameItems(a, b []Item) bool {
+	return len(a) == len(b) && (len(a) == 0 || reflect.DeepEqual(a, b))
+}
+
+// multiModel inserts n random pairs with keys in [0, keys) into a multiset
+// tree, returning the tree and the items in the order the tree should hold
+// them.
+func multiModel(n, keys int) (*BTree, []Item) {
+	tr := NewMulti(3)
+	var want []Item
+	for i := 0; i < n; i++ {
+		p := pair{rand.Intn(keys), i}
+		tr.InsertNoReplace(p)
+		want = append(want, p)
+	}
+	sort.SliceStable(want, func(i, j int) bool {
+		return want[i].Less(want[j])
+	})
+	return tr, want
+}
+
+func TestMulti(t *testing.T) {
+	tr, want := multiModel(2000, 50)
+	checkSizes(t, tr.root)
+	if got := all(tr); !reflect.DeepEqual(got, want) {
+		t.Fatalf("ascend:\n got: %v\nwant: %v", got, want)
+	}
+	var wantrev []Item
+	for i := len(want) - 1; i >= 0; i-- {
+		wantrev = append(wantrev, want[i])
+	}
+	if got := allrev(tr); !reflect.DeepEqual(got, wantrev) {
+		t.Fatalf("descend:\n got: %v\nwant: %v", got, wantrev)
+	}
+	for key := -1; key <= 50; key++ {
+		lo := sort.Search(len(want), func(i int) bool { return want[i].(pair).key >= key })
+		hi := sort.Search(len(want), func(i int) bool { return want[i].(pair).key > key })
+		if got := tr.Count(pair{key: key}); got != hi-lo {
+			t.Fatalf("Count(%d): got %d, want %d", key, got, hi-lo)
+		}
+		if r, found := tr.Rank(pair{key: key}); r != lo || found != (hi > lo) {
+			t.Fatalf("Rank(%d): got %d %v, want %d %v", key, r, found, lo, hi > lo)
+		}
+		var wantGet Item
+		if hi > lo {
+			wantGet = want[lo]
+		}
+		if got := tr.Get(pair{key: key}); got != wantGet {
+			t.Fatalf("Get(%d): got %v, want %v", key, got, wantGet)
+		}
+		var got []Item
+		tr.AscendRange(pair{key: key}, pair{key: key + 1}, func(a Item) bool {
+			got = append(got, a)
+			return true
+		})
+		if !sameItems(got, want[lo:hi]) {
+			t.Fatalf("AscendRange(%d):\n got: %v\nwant: %v", key, got, want[lo:hi])
+		}
+		got = got[:0]
+		tr.DescendLessOrEqual(pair{key: key}, func(a Item) bool {
+			got = append(got, a)
+			return true
+		})
+		if wantDesc := wantrev[len(want)-hi:]; !sameItems(got, wantDesc) {
+			t.Fatalf("DescendLessOrEqual(%d):\n got: %v\nwant: %v", key, got, wantDesc)
+		}
+		got = got[:0]
+		tr.DescendRange(pair{key: key}, pair{key: key - 1}, func(a Item) bool {
+			got = append(got, a)
+			return true
+		})
+		if wantDesc := wantrev[len(want)-hi : len(want)-lo]; !sameItems(got, wantDesc) {
+			t.Fatalf("DescendRange(%d):\n got: %v\nwant: %v", key, got, wantDesc)
+		}
+		c := tr.Cursor()
+		if ok := c.SeekGE(pair{key: key}); ok != (lo < len(want)) || ok && c.Item() != want[lo] {
+			t.Fatalf("SeekGE(%d): got %v, want %v", key, c.Item(), want[lo])
+		}
+		if ok := c.SeekLE(pair{key: key}); ok != (hi > 0) || ok && c.Item() != want[hi-1] {
+			t.Fatalf("SeekLE(%d): got %v, want %v", key, c.Item(), want[hi-1])
+		}
+	}
+}
+
+func TestMultiDelete(t *testing.T) {
+	tr, want := multiModel(2000, 50)
+	clone := tr.Clone()
+	for i := 0; i < 500; i++ {
+		key := rand.Intn(50)
+		lo := sort.Search(len(want), func(i int) bool { return want[i].(pair).key >= key })
+		got := tr.Delete(pair{key: key})
+		if lo == len(want) || want[lo].(pair).key != key {
+			if got != nil {
+				t.Fatalf("Delete(%d): got %v, want nil", key, got)
+			}
+			continue
+		}
+		if got != want[lo] {
+			t.Fatalf("Delete(%d): got %v, want %v", key, got, want[lo])
+		}
+		want = append(want[:lo], want[lo+1:]...)
+	}
+	checkSizes(t, tr.root)
+	if got := all(tr); !reflect.DeepEqual(got, want) {
+		t.Fatalf("after Delete:\n got: %v\nwant: %v", got, want)
+	}
+	for key := 0; key < 50; key += 2 {
+		count := tr.Count(pair{key: key})
+		if got := tr.DeleteAll(pair{key: key}); got != count {
+			t.Fatalf("DeleteAll(%d): got %d, want %d", key, got, count)
+		}
+		if tr.Has(pair{key: key}) {
+			t.Fatalf("DeleteAll(%d) left items behind", key)
+		}
+	}
+	checkSizes(t, tr.root)
+	if tr.Len() != len(all(tr)) {
+		t.Fatalf("len %d, but holds %d items", tr.Len(), len(all(tr)))
+	}
+	if clone.Len() != 2000 || len(all(clone)) != 2000 {
+		t.Fatalf("clone modified")
+	}
+}
+
+func TestMultiReplaceOrInsert(t *testing.T) {
+	tr := NewMulti(2)
+	for i := 0; i < 10; i++ {
+		tr.InsertNoReplace(pair{i % 3, i})
+	}
+	if got, want := tr.ReplaceOrInsert(pair{1, 100}), (pair{1, 1}); got != want {
+		t.Fatalf("ReplaceOrInsert: got %v, want %v", got, want)
+	}
+	if got := tr.ReplaceOrInsert(pair{5, 5}); got != nil {
+		t.Fatalf("ReplaceOrInsert of new key: got %v", got)
+	}
+	want := []Item{pair{0, 0}, pair{0, 3}, pair{0, 6}, pair{0, 9}, pair{1, 100}, pair{1, 4}, pair{1, 7}, pair{2, 2}, pair{2, 5}, pair{2, 8}, pair{5, 5}}
+	if got := all(tr); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v\nwant %v", got, want)
+	}
+
+	single := New(2)
+	single.InsertNoReplace(pair{1, 1})
+	single.InsertNoReplace(pair{1, 2})
+	if got := all(single); !reflect.DeepEqual(got, []Item{pair{1, 1}}) {
+		t.Fatalf("InsertNoReplace on a non-multi tree: got %v", got)
+	}
+	if got := single.Count(pair{key: 1}); got != 1 {
+		t.Fatalf("Count on a non-multi tree: got %d", got)
+	}
+}
+
+// countNodes returns the number of nodes in the subtree rooted at n.
+func countNodes(n *node) int {
+	if n == nil {
+		return 0
+	}
+	count := 1
+	for _, c := range n.children {
+		count += countNodes(c)
+	}
+	return count
+}
+
+// freeNodes returns the number of nodes in f.
+func freeNodes(f *FreeList) int {
+	free, _ := f.stats()
+	return free
+}
+
+func TestClear(t *testing.T) {
+	f := NewFreeList(1 << 16)
+	tr := NewWithFreeList(3, f)
+	for _, v := range perm(1000) {
+		tr.ReplaceOrInsert(v)
+	}
+	nodes := countNodes(tr.root)
+	tr.Clear(true)
+	if tr.Len() != 0 || tr.root != nil || len(all(tr)) != 0 {
+		t.Fatalf("tree not empty after Clear")
+	}
+	if got := freeNodes(f); got != nodes {
+		t.Fatalf("freelist holds %d nodes, want %d", got, nodes)
+	}
+	for _, v := range perm(1000) {
+		tr.ReplaceOrInsert(v)
+	}
+	if got := all(tr); !reflect.DeepEqual(got, rang(1000)) {
+		t.Fatalf("reused tree mismatch: got %d items", len(got))
+	}
+
+	// Nodes shared with a clone must stay put.
+	clone := tr.Clone()
+	for i := 0; i < 10; i++ {
+		tr.Delete(Int(i * 100))
+	}
+	before := freeNodes(f)
+	tr.Clear(true)
+	if got := all(clone); !reflect.DeepEqual(got, rang(1000)) {
+		t.Fatalf("clone modified by Clear: got %d items", len(got))
+	}
+	if freeNodes(f)-before > nodes {
+		t.Fatalf("Clear freed %d nodes, more than the tree owned", freeNodes(f)-before)
+	}
+	clone.Clear(false)
+	if clone.Len() != 0 || len(all(clone)) != 0 {
+		t.Fatalf("clone not empty after Clear")
+	}
+}
+
+func TestClearStopsWhenFreelistFull(t *testing.T) {
+	f := NewFreeList(5)
+	tr := NewWithFreeList(2, f)
+	for _, v := range perm(1000) {
+		tr.ReplaceOrInsert(v)
+	}
+	tr.Clear(true)
+	if got := freeNodes(f); got != 5 {
+		t.Fatalf("freelist holds %d nodes, want 5", got)
+	}
+}
+
+func TestDeleteRange(t *testing.T) {
+	for _, degree := range []int{2, 3, 4, *btreeDegree} {
+		for iter := 0; iter < 200; iter++ {
+			size := rand.Intn(1000)
+			tr := New(degree)
+			for _, v := range perm(size) {
+				tr.ReplaceOrInsert(v)
+			}
+			clone := tr.Clone()
+			lo, hi := rand.Intn(size+10)-5, rand.Intn(size+10)-5
+			want := []Item{}
+			for _, v := range rang(size) {
+				if int(v.(Int)) < lo || int(v.(Int)) >= hi {
+					want = append(want, v)
+				}
+			}
+			if got := tr.DeleteRange(Int(lo), Int(hi)); got != size-len(want) {
+				t.Fatalf("degree %d, DeleteRange(%d, %d) of %d: removed %d, want %d", degree, lo, hi, size, got, size-len(want))
+			}
+			if got := all(tr); !sameItems(got, want) {
+				t.Fatalf("degree %d, DeleteRange(%d, %d) of %d:\n got: %v\nwant: %v", degree, lo, hi, size, got, want)
+			}
+			if tr.Len() != len(want) {
+				t.Fatalf("len: got %d, want %d", tr.Len(), len(want))
+			}
+			if tr.root != nil {
+				checkShape(t, tr, tr.root, true)
+			}
+			checkSizes(t, tr.root)
+			if got := all(clone); !sameItems(got, rang(size)) {
+				t.Fatalf("clone modified by DeleteRange")
+			}
+			// The tree must keep working normally afterwards.
+			for _, v := range perm(size) {
+				tr.ReplaceOrInsert(v)
+			}
+			if got := all(tr); !sameItems(got, rang(size)) {
+				t.Fatalf("after reinserting: got %v", got)
+			}
+			if tr.root != nil {
+				checkShape(t, tr, tr.root, true)
+			}
+		}
+	}
+}
+
+func TestDeleteRangeMulti(t *testing.T) {
+	tr, want := multiModel(2000, 50)
+	lo := sort.Search(len(want), func(i int) bool { return want[i].(pair).key >= 10 })
+	hi := sort.Search(len(want), func(i int) bool { return want[i].(pair).key >= 20 })
+	if got := tr.DeleteRange(pair{key: 10}, pair{key: 20}); got != hi-lo {
+		t.Fatalf("removed %d, want %d", got, hi-lo)
+	}
+	want = append(want[:lo:lo], want[hi:]...)
+	if got := all(tr); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v\nwant %v", got, want)
+	}
+}
+
+func TestDeleteRangeOpen(t *testing.T) {
+	for _, test := range []struct {
+		lo, hi Item
+		want   []Item
+	}{
+		{nil, Int(30), rang(100)[30:]},
+		{Int(70), nil, rang(100)[:70]},
+		{nil, nil, []Item{}},
+		{Int(-5), nil, []Item{}},
+		{nil, Int(-5), rang(100)},
+	} {
+		tr := New(3)
+		for _, v := range perm(100) {
+			tr.ReplaceOrInsert(v)
+		}
+		if got := tr.DeleteRange(test.lo, test.hi); got != 100-len(test.want) {
+			t.Errorf("DeleteRange(%v, %v): removed %d, want %d", test.lo, test.hi, got, 100-len(test.want))
+		}
+		if got := all(tr); !sameItems(got, test.want) {
+			t.Errorf("DeleteRange(%v, %v): got %v, want %v", test.lo, test.hi, got, test.want)
+		}
+		if err := tr.Validate(); err != nil {
+			t.Errorf("DeleteRange(%v, %v): %v", test.lo, test.hi, err)
+		}
+	}
+
+	// The ranges from Partition are open at either end, and between them
+	// cover the whole tree.
+	tr := New(3)
+	for _, v := range perm(1000) {
+		tr.ReplaceOrInsert(v)
+	}
+	removed := 0
+	for _, r := range tr.Partition(7) {
+		removed += tr.DeleteRange(r.GreaterOrEqual, r.LessThan)
+		if err := tr.Validate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if removed != 1000 || tr.Len() != 0 {
+		t.Fatalf("deleting every partition removed %d items, left %d", removed, tr.Len())
+	}
+}
+
+func TestDeleteRangeFreesNodes(t *testing.T) {
+	f := NewFreeList(1 << 16)
+	tr := NewWithFreeList(2, f)
+	for _, v := range perm(1000) {
+		tr.ReplaceOrInsert(v)
+	}
+	before := freeNodes(f)
+	tr.DeleteRange(Int(100), Int(900))
+	if freeNodes(f)-before < 800/3 {
+		t.Fatalf("only %d nodes freed", freeNodes(f)-before)
+	}
+}
+
+func TestSplitAtJoin(t *testing.T) {
+	for _, degree := range []int{2, 3, 4, *btreeDegree} {
+		for iter := 0; iter < 100; iter++ {
+			size := rand.Intn(1000)
+			tr := New(degree)
+			for _, v := range perm(size) {
+				tr.ReplaceOrInsert(v)
+			}
+			pivot := rand.Intn(size+10) - 5
+			left, right := tr.SplitAt(Int(pivot))
+			var wantLeft, wantRight []Item
+			for _, v := range rang(size) {
+				if int(v.(Int)) < pivot {
+					wantLeft = append(wantLeft, v)
+				} else {
+					wantRight = append(wantRight, v)
+				}
+			}
+			for _, part := range []struct {
+				tr   *BTree
+				want []Item
+			}{{left, wantLeft}, {right, wantRight}} {
+				if got := all(part.tr); !sameItems(got, part.want) || part.tr.Len() != len(part.want) {
+					t.Fatalf("degree %d, SplitAt(%d) of %d:\n got: %v\nwant: %v", degree, pivot, size, got, part.want)
+				}
+				if part.tr.root != nil {
+					checkShape(t, part.tr, part.tr.root, true)
+				}
+				checkSizes(t, part.tr.root)
+			}
+			joined := Join(left, right)
+			if got := all(joined); !sameItems(got, rang(size)) || joined.Len() != size {
+				t.Fatalf("degree %d, Join after SplitAt(%d) of %d:\n got: %v", degree, pivot, size, got)
+			}
+			if joined.root != nil {
+				checkShape(t, joined, joined.root, true)
+			}
+			checkSizes(t, joined.root)
+			// Writing to any of the trees must not affect the others.
+			for _, v := range perm(size) {
+				left.Delete(v)
+				joined.Delete(v)
+			}
+			right.ReplaceOrInsert(Int(-1))
+			if got := all(tr); !sameItems(got, rang(size)) {
+				t.Fatalf("original modified: got %v", got)
+			}
+			if got := all(right); !sameItems(got, append([]Item{Int(-1)}, wantRight...)) {
+				t.Fatalf("right modified: got %v", got)
+			}
+		}
+	}
+}
+
+func TestSplitAtOwnership(t *testing.T) {
+	f := NewFreeList(1000)
+	tr := NewWithFreeList(2, f)
+	for _, v := range perm(1000) {
+		tr.ReplaceOrInsert(v)
+	}
+	left, right := tr.SplitAt(Int(500))
+	for _, part := range []*BTree{left, right} {
+		if s := part.Stats(); s.OwnedNodes == 0 {
+			t.Fatalf("SplitAt result owns no nodes, and shares %d", s.SharedNodes)
+		}
+	}
+	// Only the nodes built by the split can be freed, and each half frees
+	// its own.
+	before, _ := f.stats()
+	owned := right.Stats().OwnedNodes
+	right.Clear(true)
+	if freed, _ := f.stats(); freed-before != owned {
+		t.Fatalf("Clear freed %d nodes, want %d", freed-before, owned)
+	}
+	if got := all(left); !sameItems(got, rang(500)) {
+		t.Fatalf("clearing right changed left: got %v", got)
+	}
+}
+
+func TestJoinUneven(t *testing.T) {
+	for _, sizes := range [][2]int{{0, 0}, {0, 100}, {100, 0}, {1, 1000}, {1000, 1}, {10, 1000}, {1000, 10}, {500, 500}} {
+		left, right := New(2), New(2)
+		for _, v := range perm(sizes[0]) {
+			left.ReplaceOrInsert(v)
+		}
+		for _, v := range perm(sizes[1]) {
+			right.ReplaceOrInsert(Int(int(v.(Int)) + sizes[0]))
+		}
+		joined := Join(left, right)
+		if got := all(joined); !sameItems(got, rang(sizes[0]+sizes[1])) {
+			t.Fatalf("Join of %v: got %v", sizes, got)
+		}
+		if joined.root != nil {
+			checkShape(t, joined, joined.root, true)
+		}
+		checkSizes(t, joined.root)
+	}
+}
+
+func TestJoinOverlapPanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("Join of overlapping trees didn't panic")
+		}
+	}()
+	left, right := New(2), New(2)
+	left.ReplaceOrInsert(Int(5))
+	right.ReplaceOrInsert(Int(5))
+	Join(left, right)
+}
+
+func TestJoinMulti(t *testing.T) {
+	for _, leftMulti := range []bool{false, true} {
+		left, right := New(2), NewMulti(2)
+		if leftMulti {
+			left, right = right, left
+		}
+		for i := 0; i < 50; i++ {
+			left.ReplaceOrInsert(pair{i, 0})
+			right.ReplaceOrInsert(pair{i + 49, 1})
+		}
+		joined := Join(left, right)
+		if joined.Len() != 100 || !joined.multi {
+			t.Fatalf("left multi %v: joined %d items, multi %v", leftMulti, joined.Len(), joined.multi)
+		}
+		if err := joined.Validate(); err != nil {
+			t.Fatalf("left multi %v: %v", leftMulti, err)
+		}
+	}
+}
+
 const benchmarkTreeSize = 10000
 
 func BenchmarkInsert(b *testing.B) {
@@ -492,6 +1123,89 @@ func (a byInts) Swap(i, j int) {
 	a[i], a[j] = a[j], a[i]
 }
 
+// benchmarkFixed measures inserting keys into, and then getting them from,
+// trees made by newTree, which hold the fixed-size keys NewFixed specializes
+// for.
+func benchmarkFixed[K FixedKey](b *testing.B, newTree func() *BTreeG[K], key func(int) K) {
+	keys := make([]K, benchmarkTreeSize)
+	for i, v := range rand.Perm(benchmarkTreeSize) {
+		keys[i] = key(v)
+	}
+	b.Run("Insert", func(b *testing.B) {
+		for i := 0; i < b.N; {
+			tr := newTree()
+			for _, k := range keys {
+				tr.ReplaceOrInsert(k)
+				if i++; i >= b.N {
+					return
+				}
+			}
+		}
+	})
+	b.Run("Get", func(b *testing.B) {
+		tr := newTree()
+		for _, k := range keys {
+			tr.ReplaceOrInsert(k)
+		}
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			tr.Get(keys[i%benchmarkTreeSize])
+		}
+	})
+}
+
+// BenchmarkFixed compares trees from NewFixed with a BTree of Int, and with
+// trees from NewG, which store keys the same way but search with a LessFunc.
+func BenchmarkFixed(b *testing.B) {
+	b.Run("Int", func(b *testing.B) {
+		keys := perm(benchmarkTreeSize)
+		b.Run("Insert", func(b *testing.B) {
+			for i := 0; i < b.N; {
+				tr := New(*btreeDegree)
+				for _, k := range keys {
+					tr.ReplaceOrInsert(k)
+					if i++; i >= b.N {
+						return
+					}
+				}
+			}
+		})
+		b.Run("Get", func(b *testing.B) {
+			tr := New(*btreeDegree)
+			for _, k := range keys {
+				tr.ReplaceOrInsert(k)
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				tr.Get(keys[i%benchmarkTreeSize])
+			}
+		})
+	})
+	uuid := func(v int) (u UUID) {
+		binary.BigEndian.PutUint64(u[:], uint64(v)*0x9e3779b97f4a7c15)
+		binary.BigEndian.PutUint64(u[8:], uint64(v))
+		return u
+	}
+	b.Run("G/int64", func(b *testing.B) {
+		benchmarkFixed(b, func() *BTreeG[int64] { return NewG(*btreeDegree, Less[int64]()) }, func(v int) int64 { return int64(v) })
+	})
+	b.Run("G/UUID", func(b *testing.B) {
+		benchmarkFixed(b, func() *BTreeG[UUID] { return NewG(*btreeDegree, lessUUID) }, uuid)
+	})
+	b.Run("Fixed/int64", func(b *testing.B) {
+		benchmarkFixed(b, func() *BTreeG[int64] { return NewFixed[int64](*btreeDegree) }, func(v int) int64 { return int64(v) })
+	})
+	b.Run("Fixed/uint64", func(b *testing.B) {
+		benchmarkFixed(b, func() *BTreeG[uint64] { return NewFixed[uint64](*btreeDegree) }, func(v int) uint64 { return uint64(v) })
+	})
+	b.Run("Fixed/float64", func(b *testing.B) {
+		benchmarkFixed(b, func() *BTreeG[float64] { return NewFixed[float64](*btreeDegree) }, func(v int) float64 { return float64(v) / 3 })
+	})
+	b.Run("Fixed/UUID", func(b *testing.B) {
+		benchmarkFixed(b, func() *BTreeG[UUID] { return NewFixed[UUID](*btreeDegree) }, uuid)
+	})
+}
+
 func BenchmarkAscend(b *testing.B) {
 	arr := perm(benchmarkTreeSize)
 	tr := New(*btreeDegree)
@@ -687,3 +1401,60 @@ func TestCloneConcurrentOperations(t *testing.T) {
 		}
 	}
 }
+
+func BenchmarkDeleteAndRestore(b *testing.B) {
+	items := perm(16392)
+	b.ResetTimer()
+	b.Run(`CopyBigFreeList`, func(b *testing.B) {
+		fl := NewFreeList(16392)
+		tr := NewWithFreeList(*btreeDegree, fl)
+		for _, v := range items {
+			tr.ReplaceOrInsert(v)
+		}
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			dels := make([]Item, 0, tr.Len())
+			tr.Ascend(ItemIterator(func(b Item) bool {
+				dels = append(dels, b)
+				return true
+			}))
+			for _, del := range dels {
+				tr.Delete(del)
+			}
+			// tr is now empty, we make a new empty copy of it.
+			tr = NewWithFreeList(*btreeDegree, fl)
+			for _, v := range items {
+				tr.ReplaceOrInsert(v)
+			}
+		}
+	})
+	b.Run(`Clear`, func(b *testing.B) {
+		tr := New(*btreeDegree)
+		for _, v := range items {
+			tr.ReplaceOrInsert(v)
+		}
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			tr.Clear(true)
+			for _, v := range items {
+				tr.ReplaceOrInsert(v)
+			}
+		}
+	})
+}
+
+func BenchmarkDeleteRange(b *testing.B) {
+	insertP := perm(benchmarkTreeSize)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		tr := New(*btreeDegree)
+		for _, v := range insertP {
+			tr.ReplaceOrInsert(v)
+		}
+		b.StartTimer()
+		tr.DeleteRange(Int(benchmarkTreeSize/4), Int(benchmarkTreeSize*3/4))
+	}
+}
@@ -0,0 +1,201 @@
+// Copyright 2014 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package btree
+
+import (
+	"errors"
+	"fmt"
+)
+
+// DefaultFill is the fill to pass to BuildSorted or NewBuilder to fill every
+// node to capacity.
+const DefaultFill = 1.0
+
+var (
+	// ErrNotSorted is returned when bulk loading items that are not in
+	// ascending order.
+	ErrNotSorted = errors.New("btree: items not in ascending order")
+	// ErrDuplicate is returned when bulk loading two equivalent items.
+	ErrDuplicate = errors.New("btree: duplicate item")
+	// errBuilt is returned when a Builder is used after Build.
+	errBuilt = errors.New("btree: Builder already built")
+)
+
+// Builder assembles a BTree bottom-up from items supplied in ascending order,
+// in O(n) time.  Unlike repeated calls to ReplaceOrInsert, which leave most
+// nodes about half full after splitting, a Builder fills each node to a
+// configurable fraction of its capacity.
+type Builder struct {
+	t      *BTree
+	target int
+	// levels holds the node currently being filled at each level of the tree,
+	// leaves first.  Every node to the left of these is complete.
+	levels []*node
+	last   Item
+	count  int
+	err    error
+}
+
+// NewBuilder returns a Builder for a tree of the given degree whose nodes
+// allocate from f.  Each node is filled with fill*(2*degree-1) items, but
+// never fewer than the minimum a node may hold; fill must be in (0, 1].
+func NewBuilder(degree int, fill float64, f *FreeList) *Builder {
+	return newBuilder(NewWithFreeList(degree, f), fill)
+}
+
+// newBuilder returns a Builder that fills the empty tree t, allocating nodes
+// from t's copy-on-write context.
+func newBuilder(t *BTree, fill float64) *Builder {
+	if fill <= 0 || fill > 1 {
+		panic("bad fill")
+	}
+	target := int(fill*float64(t.maxItems()) + 0.5)
+	if target < t.minItems() {
+		target = t.minItems()
+	}
+	if target < 1 {
+		target = 1
+	}
+	return &Builder{
+		t:      t,
+		target: target,
+		levels: []*node{t.cow.newNode()},
+	}
+}
+
+// Add appends item to the tree being built.  item must be greater than every
+// item added before it (or, when building a multiset tree, no less); otherwise
+// an error wrapping ErrNotSorted or ErrDuplicate is returned, and the Builder
+// can no longer be used.
+//
+// nil cannot be added to the tree (will panic).
+func (b *Builder) Add(item Item) error {
+	if b.err != nil {
+		return b.err
+	}
+	if item == nil {
+		panic("nil item being added to BTree")
+	}
+	if b.last != nil && item.Less(b.last) {
+		b.err = fmt.Errorf("%w: item %d (%v) follows %v", ErrNotSorted, b.count, item, b.last)
+		return b.err
+	}
+	if b.last != nil && !b.t.multi && !b.last.Less(item) {
+		b.err = fmt.Errorf("%w: item %d (%v)", ErrDuplicate, b.count, item)
+		return b.err
+	}
+	b.push(0, item)
+	b.last = item
+	b.count++
+	return nil
+}
+
+// push appends item to the open node at the given level.  If that node is
+// full, it is complete, and item instead becomes the separator between it and
+// a new node, one level up.  After item is placed, a new open node is started
+// at every level below it.
+func (b *Builder) push(level int, item Item) {
+	if level == len(b.levels) {
+		root := b.t.cow.newNode()
+		root.children = append(root.children, b.levels[level-1])
+		b.levels = append(b.levels, root)
+	}
+	n := b.levels[level]
+	if len(n.items) >= b.target {
+		n.recount()
+		b.push(level+1, item)
+		return
+	}
+	n.items = append(n.items, item)
+	for l := level - 1; l >= 0; l-- {
+		child := b.t.cow.newNode()
+		b.levels[l+1].children = append(b.levels[l+1].children, child)
+		b.levels[l] = child
+	}
+}
+
+// Build finishes the tree and returns it.  The Builder can't be used
+// afterwards.
+func (b *Builder) Build() (*BTree, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	// The open nodes along the right edge are the only ones not yet counted.
+	for _, n := range b.levels {
+		n.recount()
+	}
+	// They may also be underfull; top them up from their left siblings,
+	// bottom-up so that merges which take items from a parent are seen when
+	// the parent is fixed in turn.
+	for l := 0; l < len(b.levels)-1; l++ {
+		if len(b.levels[l].items) < b.t.minItems() {
+			b.fixRightEdge(l)
+		}
+	}
+	t := b.t
+	t.root = b.levels[len(b.levels)-1]
+	t.length = b.count
+	if len(t.root.items) == 0 {
+		t.cow.freeNode(t.root)
+		t.root = nil
+	}
+	b.t, b.levels, b.err = nil, nil, errBuilt
+	return t, nil
+}
+
+// fixRightEdge brings the open node at the given level up to minItems items,
+// either by merging it into its left sibling or by moving items over from
+// that sibling.
+func (b *Builder) fixRightEdge(level int) {
+	parent := b.levels[level+1]
+	if len(parent.items) == 0 {
+		// The parent was only just opened and has no left sibling of ours
+		// to offer; give it an item first.
+		b.fixRightEdge(level + 1)
+		parent = b.levels[level+1]
+	}
+	parent.rebalance(len(parent.items)-1, b.t.maxItems())
+	b.levels[level] = parent.children[len(parent.children)-1]
+	if level+1 == len(b.levels)-1 && len(parent.items) == 0 {
+		b.t.cow.freeNode(parent)
+		b.levels = b.levels[:level+1]
+	}
+}
+
+// BuildSorted returns a new B-Tree of the given degree holding items, which
+// must be in strictly ascending order, and whose nodes allocate from f.  It
+// runs in O(n) time and fills every node, apart from a few along the tree's
+// right edge, to the given fraction of its capacity, as NewBuilder does.
+func BuildSorted(degree int, fill float64, f *FreeList, items []Item) (*BTree, error) {
+	b := NewBuilder(degree, fill, f)
+	for _, item := range items {
+		if err := b.Add(item); err != nil {
+			return nil, err
+		}
+	}
+	return b.Build()
+}
+
+// BuildSortedFunc is like BuildSorted, but reads its items from next until it
+// returns false.
+func BuildSortedFunc(degree int, fill float64, f *FreeList, next func() (Item, bool)) (*BTree, error) {
+	b := NewBuilder(degree, fill, f)
+	for item, ok := next(); ok; item, ok = next() {
+		if err := b.Add(item); err != nil {
+			return nil, err
+		}
+	}
+	return b.Build()
+}
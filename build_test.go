@@ -0,0 +1,159 @@
+// Copyright 2014 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package btree
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// checkShape verifies node fill and leaf depth, returning the subtree height.
+func checkShape(t *testing.T, tr *BTree, n *node, isRoot bool) int {
+	if !isRoot && (len(n.items) < tr.minItems() || len(n.items) > tr.maxItems()) {
+		t.Fatalf("node %v has %d items, want [%d, %d]", n.items, len(n.items), tr.minItems(), tr.maxItems())
+	}
+	if len(n.children) == 0 {
+		return 1
+	}
+	if len(n.children) != len(n.items)+1 {
+		t.Fatalf("node %v has %d children", n.items, len(n.children))
+	}
+	h := checkShape(t, tr, n.children[0], false)
+	for _, c := range n.children[1:] {
+		if ch := checkShape(t, tr, c, false); ch != h {
+			t.Fatalf("leaves at uneven depths %d and %d", h, ch)
+		}
+	}
+	return h + 1
+}
+
+func TestBuildSorted(t *testing.T) {
+	for _, degree := range []int{2, 3, 4, 8} {
+		for size := 0; size < 300; size++ {
+			tr, err := BuildSorted(degree, DefaultFill, NewFreeList(DefaultFreeListSize), rang(size))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tr.Len() != size {
+				t.Fatalf("degree %d size %d: len %d", degree, size, tr.Len())
+			}
+			if got := all(tr); !reflect.DeepEqual(got, rang(size)) {
+				t.Fatalf("degree %d size %d:\n got: %v", degree, size, got)
+			}
+			if tr.root != nil {
+				checkShape(t, tr, tr.root, true)
+			}
+			checkSizes(t, tr.root)
+		}
+	}
+}
+
+func TestBuilderFill(t *testing.T) {
+	for _, fill := range []float64{0.01, 0.5, 0.75, 1} {
+		for size := 0; size < 200; size++ {
+			b := NewBuilder(3, fill, NewFreeList(DefaultFreeListSize))
+			for _, item := range rang(size) {
+				if err := b.Add(item); err != nil {
+					t.Fatal(err)
+				}
+			}
+			tr, err := b.Build()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := all(tr); !reflect.DeepEqual(got, rang(size)) {
+				t.Fatalf("fill %v size %d:\n got: %v", fill, size, got)
+			}
+			if tr.root != nil {
+				checkShape(t, tr, tr.root, true)
+			}
+			checkSizes(t, tr.root)
+			// The tree must keep working normally afterwards.
+			for _, v := range perm(size) {
+				tr.Delete(v)
+				tr.ReplaceOrInsert(v)
+			}
+			if got := all(tr); !reflect.DeepEqual(got, rang(size)) {
+				t.Fatalf("fill %v size %d after writes:\n got: %v", fill, size, got)
+			}
+		}
+	}
+}
+
+func TestBuildSortedFreeList(t *testing.T) {
+	f := NewFreeList(1000)
+	old := NewWithFreeList(3, f)
+	for _, v := range perm(1000) {
+		old.ReplaceOrInsert(v)
+	}
+	old.Clear(true)
+	free, _ := f.stats()
+	tr, err := BuildSorted(3, 0.5, f, rang(300))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(all(tr), rang(300)) {
+		t.Fatalf("got %v", all(tr))
+	}
+	s := tr.Stats()
+	if left, _ := f.stats(); free-left != s.InternalNodes+s.LeafNodes {
+		t.Fatalf("built %d nodes, but took %d from the freelist", s.InternalNodes+s.LeafNodes, free-left)
+	}
+	// Half-full nodes of degree 3 hold 3 of their 5 items.
+	if got := tr.root.children[0].children[0].items; len(got) != 3 {
+		t.Fatalf("fill 0.5: leaf holds %d items, want 3", len(got))
+	}
+}
+
+func TestBuildSortedFunc(t *testing.T) {
+	i := 0
+	tr, err := BuildSortedFunc(4, DefaultFill, NewFreeList(DefaultFreeListSize), func() (Item, bool) {
+		i++
+		return Int(i), i <= 1000
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tr.Len() != 1000 || tr.Min() != Int(1) || tr.Max() != Int(1000) {
+		t.Fatalf("got len %d, min %v, max %v", tr.Len(), tr.Min(), tr.Max())
+	}
+}
+
+func TestBuildSortedErrors(t *testing.T) {
+	if _, err := BuildSorted(2, DefaultFill, NewFreeList(DefaultFreeListSize), []Item{Int(1), Int(3), Int(2)}); !errors.Is(err, ErrNotSorted) {
+		t.Fatalf("unsorted: got %v", err)
+	}
+	if _, err := BuildSorted(2, DefaultFill, NewFreeList(DefaultFreeListSize), []Item{Int(1), Int(2), Int(2)}); !errors.Is(err, ErrDuplicate) {
+		t.Fatalf("duplicate: got %v", err)
+	}
+	b := NewBuilder(2, DefaultFill, NewFreeList(DefaultFreeListSize))
+	b.Add(Int(2))
+	b.Add(Int(1))
+	if err := b.Add(Int(3)); !errors.Is(err, ErrNotSorted) {
+		t.Fatalf("Add after error: got %v", err)
+	}
+	if _, err := b.Build(); !errors.Is(err, ErrNotSorted) {
+		t.Fatalf("Build after error: got %v", err)
+	}
+}
+
+func BenchmarkBuildSorted(b *testing.B) {
+	items := rang(benchmarkTreeSize)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		BuildSorted(*btreeDegree, DefaultFill, NewFreeList(DefaultFreeListSize), items)
+	}
+}
@@ -0,0 +1,208 @@
+// Copyright 2014 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package btree
+
+// Cursor is a stateful, bidirectional iterator over the items of a BTree.
+//
+// Unlike the Ascend*/Descend* functions, a Cursor may be paused and resumed,
+// moved in either direction, and advanced in lockstep with other cursors.
+// A new Cursor is unpositioned; call one of First, Last, SeekGE or SeekLE
+// before using it.
+//
+// A Cursor reads the tree's nodes directly, so any write to the tree it was
+// created from invalidates its position and it must be repositioned before
+// further use.  To scan while the tree keeps being written, create the Cursor
+// on a Clone: the cloned nodes are never modified in place, so the cursor
+// remains valid for as long as the clone itself isn't written.
+type Cursor struct {
+	t     *BTree
+	stack []cursorFrame
+}
+
+// cursorFrame is one level of a Cursor's path from the root.  For the frame at
+// the top of the stack, index is the position of the current item in
+// n.items.  For every frame below it, index is the position in n.children of
+// the child the cursor descended into.
+type cursorFrame struct {
+	n     *node
+	index int
+}
+
+// Cursor returns a new, unpositioned Cursor over t.
+func (t *BTree) Cursor() *Cursor {
+	return &Cursor{t: t}
+}
+
+// Valid returns true if the cursor is positioned at an item.
+func (c *Cursor) Valid() bool {
+	return len(c.stack) > 0
+}
+
+// Item returns the item the cursor is positioned at, or nil if the cursor is
+// not valid.
+func (c *Cursor) Item() Item {
+	if len(c.stack) == 0 {
+		return nil
+	}
+	top := c.stack[len(c.stack)-1]
+	return top.n.items[top.index]
+}
+
+// First moves the cursor to the smallest item in the tree.  It returns false
+// if the tree is empty.
+func (c *Cursor) First() bool {
+	c.stack = c.stack[:0]
+	if c.t.root == nil || len(c.t.root.items) == 0 {
+		return false
+	}
+	c.pushFirst(c.t.root)
+	return true
+}
+
+// Last moves the cursor to the largest item in the tree.  It returns false if
+// the tree is empty.
+func (c *Cursor) Last() bool {
+	c.stack = c.stack[:0]
+	if c.t.root == nil || len(c.t.root.items) == 0 {
+		return false
+	}
+	c.pushLast(c.t.root)
+	return true
+}
+
+// SeekGE moves the cursor to the smallest item that is greater than or equal
+// to key.  It returns false if there is no such item.
+func (c *Cursor) SeekGE(key Item) bool {
+	c.stack = c.stack[:0]
+	n := c.t.root
+	if n == nil || len(n.items) == 0 {
+		return false
+	}
+	for {
+		// Find the first item not less than key.  Even when it is equal to key,
+		// we keep descending, so that the cursor lands on the first of
+		// several equivalent items.
+		i := n.items.lowerBound(key)
+		c.stack = append(c.stack, cursorFrame{n, i})
+		if len(n.children) == 0 {
+			break
+		}
+		n = n.children[i]
+	}
+	c.settleForward()
+	return c.Valid()
+}
+
+// SeekLE moves the cursor to the largest item that is less than or equal to
+// key.  It returns false if there is no such item.
+func (c *Cursor) SeekLE(key Item) bool {
+	c.stack = c.stack[:0]
+	n := c.t.root
+	if n == nil || len(n.items) == 0 {
+		return false
+	}
+	for {
+		// Find the first item greater than key; the item we want, if it's in
+		// this node, is the one right before it.
+		i := n.items.upperBound(key)
+		if len(n.children) == 0 {
+			c.stack = append(c.stack, cursorFrame{n, i - 1})
+			break
+		}
+		c.stack = append(c.stack, cursorFrame{n, i})
+		n = n.children[i]
+	}
+	c.settleBackward()
+	return c.Valid()
+}
+
+// Next moves the cursor to the next item in ascending order.  It returns false
+// if there is no such item, after which the cursor is no longer valid.
+func (c *Cursor) Next() bool {
+	if len(c.stack) == 0 {
+		return false
+	}
+	top := &c.stack[len(c.stack)-1]
+	top.index++
+	if len(top.n.children) > 0 {
+		c.pushFirst(top.n.children[top.index])
+		return true
+	}
+	c.settleForward()
+	return c.Valid()
+}
+
+// Prev moves the cursor to the previous item in ascending order.  It returns
+// false if there is no such item, after which the cursor is no longer valid.
+func (c *Cursor) Prev() bool {
+	if len(c.stack) == 0 {
+		return false
+	}
+	top := &c.stack[len(c.stack)-1]
+	if len(top.n.children) > 0 {
+		c.pushLast(top.n.children[top.index])
+		return true
+	}
+	top.index--
+	c.settleBackward()
+	return c.Valid()
+}
+
+// pushFirst pushes the path from n down to the smallest item beneath it.
+func (c *Cursor) pushFirst(n *node) {
+	for len(n.children) > 0 {
+		c.stack = append(c.stack, cursorFrame{n, 0})
+		n = n.children[0]
+	}
+	c.stack = append(c.stack, cursorFrame{n, 0})
+}
+
+// pushLast pushes the path from n down to the largest item beneath it.
+func (c *Cursor) pushLast(n *node) {
+	for len(n.children) > 0 {
+		c.stack = append(c.stack, cursorFrame{n, len(n.children) - 1})
+		n = n.children[len(n.children)-1]
+	}
+	c.stack = append(c.stack, cursorFrame{n, len(n.items) - 1})
+}
+
+// settleForward pops frames whose index has run off the end of their node's
+// items.  A parent frame's child index i then refers to items[i], which is the
+// item following everything in children[i].
+func (c *Cursor) settleForward() {
+	for len(c.stack) > 0 {
+		top := c.stack[len(c.stack)-1]
+		if top.index < len(top.n.items) {
+			return
+		}
+		c.stack = c.stack[:len(c.stack)-1]
+	}
+}
+
+// settleBackward pops frames whose index has run off the start of their
+// node's items.  A parent frame's child index i then refers to items[i-1],
+// which is the item preceding everything in children[i].
+func (c *Cursor) settleBackward() {
+	for len(c.stack) > 0 {
+		top := &c.stack[len(c.stack)-1]
+		if top.index >= 0 {
+			return
+		}
+		c.stack = c.stack[:len(c.stack)-1]
+		if len(c.stack) > 0 {
+			c.stack[len(c.stack)-1].index--
+		}
+	}
+}
@@ -0,0 +1,164 @@
+// Copyright 2014 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package btree
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCursorScan(t *testing.T) {
+	for _, degree := range []int{2, 3, *btreeDegree} {
+		tr := New(degree)
+		for _, v := range perm(1000) {
+			tr.ReplaceOrInsert(v)
+		}
+		c := tr.Cursor()
+		if c.Valid() || c.Item() != nil {
+			t.Fatalf("new cursor should not be valid")
+		}
+		var got []Item
+		for ok := c.First(); ok; ok = c.Next() {
+			got = append(got, c.Item())
+		}
+		if want := rang(1000); !reflect.DeepEqual(got, want) {
+			t.Fatalf("degree %d forward:\n got: %v\nwant: %v", degree, got, want)
+		}
+		got = got[:0]
+		for ok := c.Last(); ok; ok = c.Prev() {
+			got = append(got, c.Item())
+		}
+		if want := rangrev(1000); !reflect.DeepEqual(got, want) {
+			t.Fatalf("degree %d backward:\n got: %v\nwant: %v", degree, got, want)
+		}
+	}
+}
+
+func TestCursorSeek(t *testing.T) {
+	tr := New(2)
+	for _, v := range perm(100) {
+		tr.ReplaceOrInsert(Int(v.(Int) * 2))
+	}
+	c := tr.Cursor()
+	for i := -1; i <= 199; i++ {
+		ok := c.SeekGE(Int(i))
+		want := Int(i)
+		if i%2 != 0 {
+			want++
+		}
+		if i < 0 {
+			want = 0
+		}
+		if want > 198 {
+			if ok {
+				t.Fatalf("SeekGE(%d): got %v, want invalid", i, c.Item())
+			}
+		} else if !ok || c.Item() != want {
+			t.Fatalf("SeekGE(%d): got %v, want %v", i, c.Item(), want)
+		}
+
+		ok = c.SeekLE(Int(i))
+		want = Int(i)
+		if i%2 != 0 {
+			want--
+		}
+		if i > 198 {
+			want = 198
+		}
+		if want < 0 {
+			if ok {
+				t.Fatalf("SeekLE(%d): got %v, want invalid", i, c.Item())
+			}
+		} else if !ok || c.Item() != want {
+			t.Fatalf("SeekLE(%d): got %v, want %v", i, c.Item(), want)
+		}
+	}
+}
+
+func TestCursorChangeDirection(t *testing.T) {
+	tr := New(2)
+	for _, v := range perm(100) {
+		tr.ReplaceOrInsert(v)
+	}
+	c := tr.Cursor()
+	if !c.SeekGE(Int(50)) {
+		t.Fatal("SeekGE(50) failed")
+	}
+	for i := 51; i < 80; i++ {
+		if !c.Next() || c.Item() != Int(i) {
+			t.Fatalf("Next: got %v, want %v", c.Item(), i)
+		}
+	}
+	for i := 78; i >= 10; i-- {
+		if !c.Prev() || c.Item() != Int(i) {
+			t.Fatalf("Prev: got %v, want %v", c.Item(), i)
+		}
+	}
+	c.First()
+	if c.Prev() || c.Valid() {
+		t.Fatalf("Prev before first should invalidate the cursor")
+	}
+	c.Last()
+	if c.Next() || c.Valid() {
+		t.Fatalf("Next after last should invalidate the cursor")
+	}
+}
+
+func TestCursorEmpty(t *testing.T) {
+	tr := New(2)
+	c := tr.Cursor()
+	if c.First() || c.Last() || c.SeekGE(Int(1)) || c.SeekLE(Int(1)) || c.Next() || c.Prev() {
+		t.Fatal("cursor on empty tree should never be valid")
+	}
+	tr.ReplaceOrInsert(Int(1))
+	tr.Delete(Int(1))
+	if c.First() || c.Last() {
+		t.Fatal("cursor on emptied tree should never be valid")
+	}
+}
+
+func TestCursorOnClone(t *testing.T) {
+	tr := New(3)
+	for _, v := range perm(1000) {
+		tr.ReplaceOrInsert(v)
+	}
+	snap := tr.Clone()
+	c := snap.Cursor()
+	var got []Item
+	for ok := c.First(); ok; ok = c.Next() {
+		got = append(got, c.Item())
+		// Keep writing the original while the snapshot is being scanned.
+		tr.Delete(c.Item())
+		tr.ReplaceOrInsert(Int(1000 + len(got)))
+	}
+	if want := rang(1000); !reflect.DeepEqual(got, want) {
+		t.Fatalf("snapshot scan:\n got: %v\nwant: %v", got, want)
+	}
+}
+
+func BenchmarkCursorNext(b *testing.B) {
+	tr := New(*btreeDegree)
+	for _, v := range perm(benchmarkTreeSize) {
+		tr.ReplaceOrInsert(v)
+	}
+	c := tr.Cursor()
+	c.First()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if !c.Next() {
+			c.First()
+		}
+	}
+}
@@ -0,0 +1,191 @@
+// Copyright 2014 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package btree
+
+import (
+	"reflect"
+)
+
+// DiffOp describes how an item differs between two trees.
+type DiffOp int
+
+const (
+	// DiffAdded reports an item present only in the second tree.
+	DiffAdded DiffOp = iota + 1
+	// DiffRemoved reports an item present only in the first tree.
+	DiffRemoved
+	// DiffChanged reports an item present in both trees, but replaced by a
+	// different, equivalent item.
+	DiffChanged
+)
+
+func (op DiffOp) String() string {
+	switch op {
+	case DiffAdded:
+		return "Added"
+	case DiffRemoved:
+		return "Removed"
+	case DiffChanged:
+		return "Changed"
+	}
+	return "DiffOp(?)"
+}
+
+// DiffFunc is called by Diff for each difference between two trees.  old is
+// the item in the first tree and new the item in the second, either of which
+// is nil if the item is missing from that tree.  Returning false stops the
+// diff.
+type DiffFunc func(op DiffOp, old, new Item) bool
+
+// Diff calls fn for every difference between a and b, in ascending order.
+// Items that are equivalent in both trees are reported as DiffChanged if they
+// are not equal, as by ==, or reflect.DeepEqual for types that aren't
+// comparable.
+//
+// Diff skips any subtree that a and b share, which they do after Clone until
+// either tree is written to along it, so diffing a tree against an earlier
+// clone of itself costs time proportional to the writes made since, not to
+// the size of the tree.  In multiset trees, equivalent items are paired up in
+// order.
+func Diff(a, b *BTree, fn DiffFunc) {
+	var ia, ib diffIter
+	ia.init(a.root)
+	ib.init(b.root)
+	for {
+		na, xa, oka := ia.peek()
+		nb, xb, okb := ib.peek()
+		if na != nil || nb != nil {
+			// Only compare items once neither side is at a subtree that
+			// might hold them, stepping into whichever subtree starts first.
+			// A subtree that starts after the other side's item can wait,
+			// since the item sorts before it.
+			switch {
+			case na == nb:
+				// A shared subtree holds the same items in both trees.
+				ia.next()
+				ib.next()
+				continue
+			case na != nil && nb != nil:
+				ma, mb := min(na), min(nb)
+				if mb.Less(ma) || (!ma.Less(mb) && nb.size > na.size) {
+					ib.descend()
+				} else {
+					ia.descend()
+				}
+				continue
+			case na != nil && (!okb || !xb.Less(min(na))):
+				ia.descend()
+				continue
+			case nb != nil && (!oka || !xa.Less(min(nb))):
+				ib.descend()
+				continue
+			}
+		}
+		switch {
+		case !oka && !okb:
+			return
+		case na != nil || !oka || (okb && nb == nil && xb.Less(xa)):
+			if !fn(DiffAdded, nil, xb) {
+				return
+			}
+			ib.next()
+		case nb != nil || !okb || xa.Less(xb):
+			if !fn(DiffRemoved, xa, nil) {
+				return
+			}
+			ia.next()
+		default:
+			if !sameItem(xa, xb) && !fn(DiffChanged, xa, xb) {
+				return
+			}
+			ia.next()
+			ib.next()
+		}
+	}
+}
+
+// sameItem returns true if a and b are the same value.
+func sameItem(a, b Item) bool {
+	if reflect.TypeOf(a) != reflect.TypeOf(b) {
+		return false
+	}
+	if reflect.TypeOf(a).Comparable() {
+		return a == b
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// diffIter walks a tree in order, yielding whole subtrees, which may be
+// skipped, or descended into to reach their items.
+type diffIter struct {
+	stack []diffFrame
+}
+
+// diffFrame is a position within a node.  pos runs over the node's children
+// and items in order: even positions are children, odd positions are items.
+// In a leaf, only odd positions are used.
+type diffFrame struct {
+	n   *node
+	pos int
+}
+
+func (it *diffIter) init(root *node) {
+	if root != nil {
+		it.stack = append(it.stack, diffFrame{n: root})
+		it.settle()
+	}
+}
+
+// peek returns the subtree or item at the current position, or false if the
+// walk is done.
+func (it *diffIter) peek() (*node, Item, bool) {
+	if len(it.stack) == 0 {
+		return nil, nil, false
+	}
+	f := it.stack[len(it.stack)-1]
+	if f.pos%2 == 0 {
+		return f.n.children[f.pos/2], nil, true
+	}
+	return nil, f.n.items[f.pos/2], true
+}
+
+// next moves past the current subtree or item.
+func (it *diffIter) next() {
+	it.stack[len(it.stack)-1].pos++
+	it.settle()
+}
+
+// descend moves into the current subtree.
+func (it *diffIter) descend() {
+	f := it.stack[len(it.stack)-1]
+	it.stack[len(it.stack)-1].pos++
+	it.stack = append(it.stack, diffFrame{n: f.n.children[f.pos/2]})
+	it.settle()
+}
+
+// settle moves the top frame to a valid position, skipping leaves' child
+// positions and popping finished nodes.
+func (it *diffIter) settle() {
+	for len(it.stack) > 0 {
+		f := &it.stack[len(it.stack)-1]
+		if len(f.n.children) == 0 && f.pos%2 == 0 {
+			f.pos++
+		}
+		if f.pos <= 2*len(f.n.items) && (f.pos%2 == 1 || len(f.n.children) > 0) {
+			return
+		}
+		it.stack = it.stack[:len(it.stack)-1]
+	}
+}
@@ -0,0 +1,173 @@
+// Copyright 2014 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package btree
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+type diffEntry struct {
+	op       DiffOp
+	old, new Item
+}
+
+func diffAll(a, b *BTree) (out []diffEntry) {
+	Diff(a, b, func(op DiffOp, old, new Item) bool {
+		out = append(out, diffEntry{op, old, new})
+		return true
+	})
+	return out
+}
+
+// diffModel computes the expected diff of two sets of pairs by merging them.
+func diffModel(a, b []Item) (out []diffEntry) {
+	for len(a) > 0 || len(b) > 0 {
+		switch {
+		case len(b) == 0 || (len(a) > 0 && a[0].Less(b[0])):
+			out = append(out, diffEntry{DiffRemoved, a[0], nil})
+			a = a[1:]
+		case len(a) == 0 || b[0].Less(a[0]):
+			out = append(out, diffEntry{DiffAdded, nil, b[0]})
+			b = b[1:]
+		default:
+			if a[0] != b[0] {
+				out = append(out, diffEntry{DiffChanged, a[0], b[0]})
+			}
+			a, b = a[1:], b[1:]
+		}
+	}
+	return out
+}
+
+func TestDiff(t *testing.T) {
+	for _, writes := range []int{0, 1, 10, 100, 2000} {
+		a := New(*btreeDegree)
+		for i := 0; i < 2000; i++ {
+			a.ReplaceOrInsert(pair{i * 2, 0})
+		}
+		b := a.Clone()
+		r := rand.New(rand.NewSource(int64(writes)))
+		for i := 0; i < writes; i++ {
+			tr := b
+			if i%4 == 0 {
+				tr = a
+			}
+			switch k := r.Intn(4200); r.Intn(3) {
+			case 0:
+				tr.Delete(pair{k, 0})
+			case 1:
+				tr.ReplaceOrInsert(pair{k, i + 1})
+			case 2:
+				tr.DeleteRange(pair{k, 0}, pair{k + 20, 0})
+			}
+		}
+		got := diffAll(a, b)
+		if want := diffModel(all(a), all(b)); !reflect.DeepEqual(got, want) {
+			t.Fatalf("%d writes:\n got: %v\nwant: %v", writes, got, want)
+		}
+		// Diffing the other way round swaps additions and removals.
+		back := diffAll(b, a)
+		if len(back) != len(got) {
+			t.Fatalf("%d writes: reverse diff has %d entries, want %d", writes, len(back), len(got))
+		}
+	}
+}
+
+func TestDiffUnrelated(t *testing.T) {
+	a, b := New(2), New(5)
+	for _, v := range perm(500) {
+		a.ReplaceOrInsert(v)
+	}
+	for i := 250; i < 750; i++ {
+		b.ReplaceOrInsert(Int(i))
+	}
+	got := diffAll(a, b)
+	if want := diffModel(all(a), all(b)); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got: %v\nwant: %v", got, want)
+	}
+	if got := diffAll(New(2), New(2)); len(got) != 0 {
+		t.Fatalf("empty trees differ: %v", got)
+	}
+}
+
+func TestDiffStop(t *testing.T) {
+	a := New(*btreeDegree)
+	b := New(*btreeDegree)
+	for _, v := range perm(100) {
+		b.ReplaceOrInsert(v)
+	}
+	var got []Item
+	Diff(a, b, func(op DiffOp, old, new Item) bool {
+		got = append(got, new)
+		return len(got) < 10
+	})
+	if want := rang(10); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+// countedInt is an Int that counts its comparisons.
+type countedInt int
+
+var countedLess int
+
+func (a countedInt) Less(b Item) bool {
+	countedLess++
+	return a < b.(countedInt)
+}
+
+func TestDiffSkipsSharedNodes(t *testing.T) {
+	a := New(*btreeDegree)
+	for i := 0; i < 100000; i++ {
+		a.ReplaceOrInsert(countedInt(i))
+	}
+	b := a.Clone()
+	b.Delete(countedInt(5000))
+	b.ReplaceOrInsert(countedInt(-1))
+	countedLess = 0
+	got := diffAll(a, b)
+	want := []diffEntry{
+		{DiffAdded, nil, countedInt(-1)},
+		{DiffRemoved, countedInt(5000), nil},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if countedLess > 1000 {
+		t.Errorf("diffing two changes took %d comparisons", countedLess)
+	}
+}
+
+func (e diffEntry) String() string {
+	return fmt.Sprintf("%v(%v, %v)", e.op, e.old, e.new)
+}
+
+func BenchmarkDiffClone(b *testing.B) {
+	tr := New(*btreeDegree)
+	for _, v := range perm(benchmarkTreeSize) {
+		tr.ReplaceOrInsert(v)
+	}
+	clone := tr.Clone()
+	for i := 0; i < 10; i++ {
+		clone.ReplaceOrInsert(Int(benchmarkTreeSize + i))
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Diff(tr, clone, func(DiffOp, Item, Item) bool { return true })
+	}
+}
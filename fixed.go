@@ -0,0 +1,189 @@
+// Copyright 2014 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package btree
+
+import (
+	"encoding/binary"
+	"math"
+	"math/bits"
+)
+
+// UUID is a 16-byte key, such as a UUID, ordered bytewise.
+type UUID [16]byte
+
+// FixedKey is the set of fixed-size key types NewFixed specializes for.
+type FixedKey interface {
+	int64 | uint64 | float64 | UUID
+}
+
+// NewFixed creates a new BTreeG of fixed-size keys with the given degree.
+//
+// Each node stores its keys in one contiguous []K, and is searched with a
+// branch-free binary search that compares keys directly, instead of calling
+// a LessFunc for each comparison as a tree from NewG does.  The search takes
+// the same number of steps for every key, so it doesn't suffer the branch
+// mispredictions of a search that stops early, which is a good trade for the
+// small, cache-resident arrays in a node.
+//
+// Keys are ordered by <, or bytewise for UUID.  float64 keys must not be NaN,
+// and -0 and +0 are the same key.
+func NewFixed[K FixedKey](degree int) *BTreeG[K] {
+	return NewFixedWithFreeList(degree, NewFreeListG[K](DefaultFreeListSize))
+}
+
+// NewFixedWithFreeList creates a new BTreeG of fixed-size keys, as NewFixed,
+// that uses the given node free list.
+func NewFixedWithFreeList[K FixedKey](degree int, f *FreeListG[K]) *BTreeG[K] {
+	var less, search interface{}
+	switch any(*new(K)).(type) {
+	case int64:
+		less, search = Less[int64](), searchInt64
+	case uint64:
+		less, search = Less[uint64](), searchUint64
+	case float64:
+		less, search = Less[float64](), searchFloat64
+	case UUID:
+		less, search = LessFunc[UUID](lessUUID), searchUUID
+	}
+	t := NewWithFreeListG(degree, less.(LessFunc[K]), f)
+	t.cow.search = search.(func([]K, K) (int, bool))
+	return t
+}
+
+// The searches below return the index where key should be inserted into s,
+// or the index of the key equal to it, and whether it was found, as
+// itemsG.find does.
+//
+// Each step halves the part of s still to search, and moves its base by the
+// size of the lower half unless the last key in that half is after key.  That
+// test is made with the borrow out of a subtraction, turned into a mask, since
+// the compiler won't reliably turn a loop-carried if into a conditional move.
+// So the loop runs the same ceil(log2(len(s))) times whatever the key, with no
+// branches to mispredict.  Signed and floating-point keys are first mapped to
+// unsigned integers which sort the same way.
+
+// found returns the search result for key, given the index of the first key
+// in s after it.
+func found[K FixedKey](s []K, i int, key K) (int, bool) {
+	if i > 0 && s[i-1] == key {
+		return i - 1, true
+	}
+	return i, false
+}
+
+// stepUnlessAfter returns step if v <= key, and 0 otherwise.
+func stepUnlessAfter(step int, v, key uint64) int {
+	_, borrow := bits.Sub64(key, v, 0)
+	return step &^ -int(borrow)
+}
+
+func searchUint64(s []uint64, key uint64) (int, bool) {
+	n := len(s)
+	if n == 0 {
+		return 0, false
+	}
+	base := 0
+	for n > 1 {
+		half := n / 2
+		base += stepUnlessAfter(half, s[base+half-1], key)
+		n -= half
+	}
+	base += stepUnlessAfter(1, s[base], key)
+	return found(s, base, key)
+}
+
+// orderInt64 maps v to a uint64, preserving order, by flipping its sign bit.
+func orderInt64(v int64) uint64 {
+	return uint64(v) ^ 1<<63
+}
+
+func searchInt64(s []int64, key int64) (int, bool) {
+	n := len(s)
+	if n == 0 {
+		return 0, false
+	}
+	k := orderInt64(key)
+	base := 0
+	for n > 1 {
+		half := n / 2
+		base += stepUnlessAfter(half, orderInt64(s[base+half-1]), k)
+		n -= half
+	}
+	base += stepUnlessAfter(1, orderInt64(s[base]), k)
+	return found(s, base, key)
+}
+
+// orderFloat64 maps v, which must not be NaN, to a uint64, preserving order.
+// Positive numbers have their sign bit set, and negative ones have all their
+// bits flipped, so that larger magnitudes sort lower.  Adding zero turns -0
+// into +0, so the two map to the same integer.
+func orderFloat64(v float64) uint64 {
+	b := math.Float64bits(v + 0)
+	return b ^ (uint64(int64(b)>>63) | 1<<63)
+}
+
+func searchFloat64(s []float64, key float64) (int, bool) {
+	n := len(s)
+	if n == 0 {
+		return 0, false
+	}
+	k := orderFloat64(key)
+	base := 0
+	for n > 1 {
+		half := n / 2
+		base += stepUnlessAfter(half, orderFloat64(s[base+half-1]), k)
+		n -= half
+	}
+	base += stepUnlessAfter(1, orderFloat64(s[base]), k)
+	return found(s, base, key)
+}
+
+func lessUUID(a, b UUID) bool {
+	ah, al := splitUUID(a)
+	bh, bl := splitUUID(b)
+	return ah < bh || (ah == bh && al < bl)
+}
+
+// splitUUID returns the high and low halves of u as big-endian integers,
+// which order the same way as u's bytes.
+func splitUUID(u UUID) (hi, lo uint64) {
+	return binary.BigEndian.Uint64(u[:8]), binary.BigEndian.Uint64(u[8:])
+}
+
+// stepUnlessAfterUUID is stepUnlessAfter for the 128-bit integers (h, l) and
+// (kh, kl).
+func stepUnlessAfterUUID(step int, h, l, kh, kl uint64) int {
+	_, borrow := bits.Sub64(kl, l, 0)
+	_, borrow = bits.Sub64(kh, h, borrow)
+	return step &^ -int(borrow)
+}
+
+func searchUUID(s []UUID, key UUID) (int, bool) {
+	n := len(s)
+	if n == 0 {
+		return 0, false
+	}
+	kh, kl := splitUUID(key)
+	base := 0
+	for n > 1 {
+		half := n / 2
+		h, l := splitUUID(s[base+half-1])
+		base += stepUnlessAfterUUID(half, h, l, kh, kl)
+		n -= half
+	}
+	h, l := splitUUID(s[base])
+	base += stepUnlessAfterUUID(1, h, l, kh, kl)
+	return found(s, base, key)
+}
@@ -0,0 +1,113 @@
+// Copyright 2014 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package btree
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// checkFixed runs random writes against a NewFixed tree and a NewG tree of
+// the same keys, checking that they agree.
+func checkFixed[K FixedKey](t *testing.T, less LessFunc[K], key func(r *rand.Rand) K) {
+	t.Helper()
+	r := rand.New(rand.NewSource(1))
+	for _, degree := range []int{2, 3, 8, 32} {
+		fixed, model := NewFixed[K](degree), NewG(degree, less)
+		for i := 0; i < 5000; i++ {
+			k := key(r)
+			if r.Intn(3) == 0 {
+				got, gotOK := fixed.Delete(k)
+				want, wantOK := model.Delete(k)
+				if got != want || gotOK != wantOK {
+					t.Fatalf("degree %d: Delete(%v) = %v, %v, want %v, %v", degree, k, got, gotOK, want, wantOK)
+				}
+			} else {
+				fixed.ReplaceOrInsert(k)
+				model.ReplaceOrInsert(k)
+			}
+			k = key(r)
+			if got, want := fixed.Has(k), model.Has(k); got != want {
+				t.Fatalf("degree %d: Has(%v) = %v, want %v", degree, k, got, want)
+			}
+		}
+		var got, want []K
+		fixed.Ascend(func(k K) bool { got = append(got, k); return true })
+		model.Ascend(func(k K) bool { want = append(want, k); return true })
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("degree %d: got %d keys, want %d", degree, len(got), len(want))
+		}
+	}
+}
+
+func TestFixed(t *testing.T) {
+	t.Run("int64", func(t *testing.T) {
+		checkFixed(t, Less[int64](), func(r *rand.Rand) int64 { return r.Int63n(2000) - 1000 })
+	})
+	t.Run("uint64", func(t *testing.T) {
+		checkFixed(t, Less[uint64](), func(r *rand.Rand) uint64 { return uint64(r.Intn(1000)) << 54 })
+	})
+	t.Run("float64", func(t *testing.T) {
+		checkFixed(t, Less[float64](), func(r *rand.Rand) float64 { return float64(r.Intn(2000)-1000) / 8 })
+	})
+	t.Run("UUID", func(t *testing.T) {
+		checkFixed(t, func(a, b UUID) bool { return bytes.Compare(a[:], b[:]) < 0 }, func(r *rand.Rand) UUID {
+			var u UUID
+			binary.BigEndian.PutUint16(u[0:], uint16(r.Intn(4)))
+			binary.BigEndian.PutUint64(u[8:], uint64(r.Intn(500))<<40|1)
+			return u
+		})
+	})
+}
+
+func TestFixedSearch(t *testing.T) {
+	floats := []float64{math.Inf(-1), -math.MaxFloat64, -1, -math.SmallestNonzeroFloat64, 0, math.SmallestNonzeroFloat64, 1, math.MaxFloat64, math.Inf(1)}
+	ints := []int64{math.MinInt64, -1, 0, 1, math.MaxInt64}
+	uints := []uint64{0, 1, 1 << 63, math.MaxUint64}
+	for n := 0; n <= len(floats); n++ {
+		for _, k := range floats {
+			s := floats[:n]
+			want, wantFound := itemsG[float64](s).find(k, Less[float64]())
+			if got, found := searchFloat64(s, k); got != want || found != wantFound {
+				t.Errorf("searchFloat64(%v, %v) = %d, %v, want %d, %v", s, k, got, found, want, wantFound)
+			}
+		}
+	}
+	for n := 0; n <= len(ints); n++ {
+		for _, k := range ints {
+			s := ints[:n]
+			want, wantFound := itemsG[int64](s).find(k, Less[int64]())
+			if got, found := searchInt64(s, k); got != want || found != wantFound {
+				t.Errorf("searchInt64(%v, %v) = %d, %v, want %d, %v", s, k, got, found, want, wantFound)
+			}
+		}
+	}
+	for n := 0; n <= len(uints); n++ {
+		for _, k := range uints {
+			s := uints[:n]
+			want, wantFound := itemsG[uint64](s).find(k, Less[uint64]())
+			if got, found := searchUint64(s, k); got != want || found != wantFound {
+				t.Errorf("searchUint64(%v, %v) = %d, %v, want %d, %v", s, k, got, found, want, wantFound)
+			}
+		}
+	}
+	if i, found := searchFloat64([]float64{-1, math.Copysign(0, -1), 1}, 0); i != 1 || !found {
+		t.Errorf("+0 does not match -0: got %d, %v", i, found)
+	}
+}
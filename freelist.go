@@ -0,0 +1,142 @@
+// Copyright 2014 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package btree
+
+import (
+	"math/rand/v2"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// nodePool is the storage behind FreeList and FreeListG: a bounded stack of
+// free nodes, split into shards so that goroutines freeing and reusing nodes
+// at the same time seldom contend for a lock.
+//
+// Each call starts at a randomly chosen shard, and falls back to a shared
+// overflow shard, then to the other shards, so the pool as a whole holds up
+// to its full size no matter which shards the nodes land in.  Half the size is
+// spread over the shards and the rest kept for the overflow shard.  There is
+// a shard for each P, unless that would leave fewer than minShardNodes in
+// each, so a small pool, like the default one each tree gets, has fewer
+// shards or only the overflow one.
+type nodePool[N any] struct {
+	shards   []poolShard[N]
+	mask     uint32
+	overflow poolShard[N]
+}
+
+// minShardNodes is the fewest nodes a shard other than the overflow one holds.
+const minShardNodes = 4
+
+type poolShard[N any] struct {
+	mu    sync.Mutex
+	count atomic.Int32 // len(nodes), for checking without taking mu
+	size  int          // cap(nodes), which never changes
+	nodes []*N
+	_     [64]byte // keeps neighbouring shards off the same cache line
+}
+
+func (p *nodePool[N]) init(size int) {
+	nodes := make([]*N, size)
+	shards := 1
+	for shards < runtime.GOMAXPROCS(0) {
+		shards *= 2
+	}
+	for shards > 1 && size/(2*shards) < minShardNodes {
+		shards /= 2
+	}
+	if per := size / (2 * shards); per >= minShardNodes {
+		p.shards = make([]poolShard[N], shards)
+		p.mask = uint32(shards - 1)
+		for i := range p.shards {
+			p.shards[i].nodes, p.shards[i].size, nodes = nodes[:0:per], per, nodes[per:]
+		}
+	}
+	p.overflow.nodes, p.overflow.size = nodes[:0], len(nodes)
+}
+
+// get returns a node from the pool, or nil if it is empty.
+func (p *nodePool[N]) get() *N {
+	if len(p.shards) > 0 {
+		if n := p.shards[rand.Uint32()&p.mask].pop(); n != nil {
+			return n
+		}
+	}
+	if n := p.overflow.pop(); n != nil {
+		return n
+	}
+	for i := range p.shards {
+		if n := p.shards[i].pop(); n != nil {
+			return n
+		}
+	}
+	return nil
+}
+
+// put adds n to the pool, returning false if the pool is full.
+func (p *nodePool[N]) put(n *N) bool {
+	if len(p.shards) > 0 && p.shards[rand.Uint32()&p.mask].push(n) {
+		return true
+	}
+	if p.overflow.push(n) {
+		return true
+	}
+	for i := range p.shards {
+		if p.shards[i].push(n) {
+			return true
+		}
+	}
+	return false
+}
+
+// stats returns the number of nodes in the pool, and the most it will hold.
+func (p *nodePool[N]) stats() (free, size int) {
+	free, size = int(p.overflow.count.Load()), p.overflow.size
+	for i := range p.shards {
+		free += int(p.shards[i].count.Load())
+		size += p.shards[i].size
+	}
+	return
+}
+
+func (s *poolShard[N]) pop() (n *N) {
+	if s.count.Load() == 0 {
+		return nil
+	}
+	s.mu.Lock()
+	if index := len(s.nodes) - 1; index >= 0 {
+		n = s.nodes[index]
+		s.nodes[index] = nil
+		s.nodes = s.nodes[:index]
+		s.count.Store(int32(index))
+	}
+	s.mu.Unlock()
+	return
+}
+
+func (s *poolShard[N]) push(n *N) (out bool) {
+	if int(s.count.Load()) == s.size {
+		return false
+	}
+	s.mu.Lock()
+	if len(s.nodes) < cap(s.nodes) {
+		s.nodes = append(s.nodes, n)
+		s.count.Store(int32(len(s.nodes)))
+		out = true
+	}
+	s.mu.Unlock()
+	return
+}
@@ -0,0 +1,120 @@
+// Copyright 2014 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package btree
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"testing"
+)
+
+func TestFreeListSize(t *testing.T) {
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(8))
+	for _, size := range []int{0, 1, 5, DefaultFreeListSize, 100, 1 << 12} {
+		f := NewFreeList(size)
+		if free, got := f.stats(); free != 0 || got != size {
+			t.Fatalf("NewFreeList(%d): holds %d of %d", size, free, got)
+		}
+		nodes := make([]*node, size+10)
+		for i := range nodes {
+			nodes[i] = new(node)
+			if got, want := f.freeNode(nodes[i]), i < size; got != want {
+				t.Fatalf("size %d: freeNode %d returned %v", size, i, got)
+			}
+		}
+		if free, _ := f.stats(); free != size {
+			t.Fatalf("size %d: full list holds %d", size, free)
+		}
+		seen := make(map[*node]bool)
+		for i := 0; i < size; i++ {
+			n := f.newNode()
+			if seen[n] {
+				t.Fatalf("size %d: newNode returned a node twice", size)
+			}
+			seen[n] = true
+		}
+		if free, _ := f.stats(); free != 0 {
+			t.Fatalf("size %d: emptied list holds %d", size, free)
+		}
+		for _, n := range nodes[:size] {
+			if !seen[n] {
+				t.Fatalf("size %d: freed node was never reused", size)
+			}
+		}
+	}
+}
+
+func TestFreeListConcurrent(t *testing.T) {
+	f := NewFreeList(256)
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			tr := NewWithFreeList(2, f)
+			for i := 0; i < 20; i++ {
+				for _, v := range perm(200) {
+					tr.ReplaceOrInsert(v)
+				}
+				if err := tr.Validate(); err != nil {
+					t.Errorf("goroutine %d: %v", g, err)
+					return
+				}
+				tr.Clear(true)
+			}
+		}(g)
+	}
+	wg.Wait()
+	if free, size := f.stats(); free > size {
+		t.Fatalf("list holds %d nodes, more than its size %d", free, size)
+	}
+}
+
+// BenchmarkFreeListParallel has each goroutine write its own tree, with all the
+// trees sharing one FreeList, at a range of GOMAXPROCS settings.  The
+// single-lock variant keeps every node in one shard, as the FreeList once did,
+// for comparison.  On a machine with fewer CPUs than GOMAXPROCS, the
+// goroutines take turns, and neither variant sees much contention.
+func BenchmarkFreeListParallel(b *testing.B) {
+	for _, procs := range []int{1, 2, 4, 8, 16} {
+		for _, sharded := range []bool{true, false} {
+			name := fmt.Sprintf("procs=%d/sharded", procs)
+			if !sharded {
+				name = fmt.Sprintf("procs=%d/single-lock", procs)
+			}
+			b.Run(name, func(b *testing.B) {
+				defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(procs))
+				f := NewFreeList(1 << 14)
+				if !sharded {
+					f = new(FreeList)
+					f.pool.overflow.nodes = make([]*node, 0, 1<<14)
+					f.pool.overflow.size = 1 << 14
+				}
+				b.RunParallel(func(pb *testing.PB) {
+					tr := NewWithFreeList(2, f)
+					i := 0
+					for pb.Next() {
+						tr.ReplaceOrInsert(Int(i))
+						if i++; i == 256 {
+							tr.Clear(true)
+							i = 0
+						}
+					}
+				})
+			})
+		}
+	}
+}
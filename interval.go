@@ -0,0 +1,149 @@
+// Copyright 2014 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package btree
+
+// Interval is an item of an IntervalTree: a half-open interval [Start, End),
+// whose bounds are themselves Items, compared with their Less methods.
+type Interval interface {
+	// Less orders intervals in the tree.  It must order them by Start first,
+	// and may break ties between intervals with equal starts however it
+	// likes, for instance by End, or by some ID.  Intervals it considers
+	// equal replace each other, as in a BTree.
+	Item
+	Start() Item
+	End() Item
+}
+
+// IntervalIterator allows callers of IntervalTree queries to iterate over
+// the matching intervals, in order.  When it returns false, the query stops.
+type IntervalIterator func(iv Interval) bool
+
+// IntervalTree is a BTree of Intervals, which can find the k intervals
+// overlapping a range or containing a point in O((k+1) log n) time.
+//
+// It is a BTree ordered by interval start, whose nodes each cache the
+// greatest end of any interval in their subtree, as an aggregate (see
+// Monoid).  A query skips every subtree whose intervals all end before it,
+// and stops at the first interval starting after it.
+type IntervalTree struct {
+	t *BTree
+}
+
+// maxEnd aggregates intervals to the greatest of their ends, or nil for none.
+var maxEnd = &Monoid{
+	Measure: func(a Item) interface{} { return a.(Interval).End() },
+	Combine: func(a, b interface{}) interface{} {
+		if a == nil {
+			return b
+		}
+		if b == nil || b.(Item).Less(a.(Item)) {
+			return a
+		}
+		return b
+	},
+}
+
+// NewInterval creates a new, empty IntervalTree with the given degree.
+func NewInterval(degree int) *IntervalTree {
+	return NewIntervalWithFreeList(degree, NewFreeList(DefaultFreeListSize))
+}
+
+// NewIntervalWithFreeList creates a new, empty IntervalTree that uses the
+// given node free list.
+func NewIntervalWithFreeList(degree int, f *FreeList) *IntervalTree {
+	t := NewWithFreeList(degree, f)
+	t.SetMonoid(maxEnd)
+	return &IntervalTree{t: t}
+}
+
+// Insert adds iv to the tree.  If the tree already holds an interval equal
+// to iv, it is replaced and returned; otherwise Insert returns nil.
+func (it *IntervalTree) Insert(iv Interval) Interval {
+	if out := it.t.ReplaceOrInsert(iv); out != nil {
+		return out.(Interval)
+	}
+	return nil
+}
+
+// Delete removes the interval equal to iv from the tree, returning it, or
+// nil if there is none.
+func (it *IntervalTree) Delete(iv Interval) Interval {
+	if out := it.t.Delete(iv); out != nil {
+		return out.(Interval)
+	}
+	return nil
+}
+
+// Len returns the number of intervals in the tree.
+func (it *IntervalTree) Len() int {
+	return it.t.Len()
+}
+
+// Clone clones the tree lazily, as BTree.Clone does.
+func (it *IntervalTree) Clone() *IntervalTree {
+	return &IntervalTree{t: it.t.Clone()}
+}
+
+// Ascend calls the iterator for every interval in the tree, in order, until
+// iterator returns false.
+func (it *IntervalTree) Ascend(iterator IntervalIterator) {
+	it.t.Ascend(func(a Item) bool { return iterator(a.(Interval)) })
+}
+
+// Overlapping calls the iterator, in order, for every interval in the tree
+// that overlaps the half-open range [lo, hi): that is, which starts before
+// hi and ends after lo.  If lo is not less than hi, the range is empty, and
+// nothing overlaps it.
+func (it *IntervalTree) Overlapping(lo, hi Item, iterator IntervalIterator) {
+	if it.t.root != nil && lo.Less(hi) {
+		it.t.root.overlapping(lo, hi, false, iterator)
+	}
+}
+
+// Stabbing calls the iterator, in order, for every interval in the tree that
+// contains point: that is, which starts at or before point and ends after it.
+func (it *IntervalTree) Stabbing(point Item, iterator IntervalIterator) {
+	if it.t.root != nil {
+		it.t.root.overlapping(point, point, true, iterator)
+	}
+}
+
+// overlapping calls iterator for the intervals in the subtree that end after
+// lo and start before hi, or at it if inclusive.  It returns false once the
+// iteration should stop, either because iterator returned false or because
+// an interval starting past hi was reached.
+func (n *node) overlapping(lo, hi Item, inclusive bool, iterator IntervalIterator) bool {
+	if n.agg == nil || !lo.Less(n.agg.(Item)) {
+		// Every interval here ends at or before lo.
+		return true
+	}
+	for i, item := range n.items {
+		if len(n.children) > 0 && !n.children[i].overlapping(lo, hi, inclusive, iterator) {
+			return false
+		}
+		iv := item.(Interval)
+		start := iv.Start()
+		if hi.Less(start) || !inclusive && !start.Less(hi) {
+			return false
+		}
+		if lo.Less(iv.End()) && !iterator(iv) {
+			return false
+		}
+	}
+	if len(n.children) > 0 {
+		return n.children[len(n.items)].overlapping(lo, hi, inclusive, iterator)
+	}
+	return true
+}
@@ -0,0 +1,135 @@
+// Copyright 2014 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package btree
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// span is an Interval ordered by start, then end.
+type span struct {
+	start, end int
+}
+
+func (a span) Less(b Item) bool {
+	s := b.(span)
+	return a.start < s.start || a.start == s.start && a.end < s.end
+}
+
+func (a span) Start() Item { return Int(a.start) }
+func (a span) End() Item   { return Int(a.end) }
+
+func collectSpans(query func(IntervalIterator)) (out []span) {
+	query(func(iv Interval) bool {
+		out = append(out, iv.(span))
+		return true
+	})
+	return out
+}
+
+func TestIntervalTree(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	it := NewInterval(*btreeDegree)
+	model := map[span]bool{}
+	for i := 0; i < 2000; i++ {
+		s := r.Intn(1000)
+		iv := span{s, s + 1 + r.Intn(50)}
+		if r.Intn(4) == 0 {
+			it.Delete(iv)
+			delete(model, iv)
+		} else {
+			it.Insert(iv)
+			model[iv] = true
+		}
+	}
+	if it.Len() != len(model) {
+		t.Fatalf("Len %d, want %d", it.Len(), len(model))
+	}
+	all := collectSpans(it.Ascend)
+	for q := 0; q < 200; q++ {
+		lo := r.Intn(1100) - 50
+		hi := lo + r.Intn(30)
+		var want []span
+		for _, iv := range all {
+			if iv.start < hi && iv.end > lo && lo < hi {
+				want = append(want, iv)
+			}
+		}
+		got := collectSpans(func(f IntervalIterator) { it.Overlapping(Int(lo), Int(hi), f) })
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("Overlapping(%d, %d):\n got: %v\nwant: %v", lo, hi, got, want)
+		}
+		want = want[:0]
+		for _, iv := range all {
+			if iv.start <= lo && iv.end > lo {
+				want = append(want, iv)
+			}
+		}
+		got = collectSpans(func(f IntervalIterator) { it.Stabbing(Int(lo), f) })
+		if len(got) != len(want) || (len(want) > 0 && !reflect.DeepEqual(got, want)) {
+			t.Fatalf("Stabbing(%d):\n got: %v\nwant: %v", lo, got, want)
+		}
+	}
+	// An empty range overlaps nothing, even an interval around it.
+	it.Insert(span{0, 10})
+	for _, q := range [][2]int{{5, 5}, {7, 3}} {
+		if got := collectSpans(func(f IntervalIterator) { it.Overlapping(Int(q[0]), Int(q[1]), f) }); got != nil {
+			t.Fatalf("Overlapping(%d, %d): got %v", q[0], q[1], got)
+		}
+	}
+}
+
+func TestIntervalTreeClone(t *testing.T) {
+	it := NewInterval(2)
+	for i := 0; i < 100; i++ {
+		it.Insert(span{i * 10, i*10 + 5})
+	}
+	c := it.Clone()
+	c.Insert(span{0, 1000})
+	c.Delete(span{500, 505})
+	if got := collectSpans(func(f IntervalIterator) { it.Stabbing(Int(502), f) }); !reflect.DeepEqual(got, []span{{500, 505}}) {
+		t.Fatalf("original: got %v", got)
+	}
+	if got := collectSpans(func(f IntervalIterator) { c.Stabbing(Int(502), f) }); !reflect.DeepEqual(got, []span{{0, 1000}}) {
+		t.Fatalf("clone: got %v", got)
+	}
+	// Stopping early.
+	n := 0
+	c.Overlapping(Int(0), Int(1000), func(Interval) bool {
+		n++
+		return n < 3
+	})
+	if n != 3 {
+		t.Fatalf("iterator called %d times after returning false", n)
+	}
+	if got := collectSpans(func(f IntervalIterator) { NewInterval(2).Overlapping(Int(0), Int(10), f) }); got != nil {
+		t.Fatalf("empty tree: got %v", got)
+	}
+}
+
+func BenchmarkIntervalStabbing(b *testing.B) {
+	it := NewInterval(*btreeDegree)
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < benchmarkTreeSize; i++ {
+		s := r.Intn(benchmarkTreeSize * 10)
+		it.Insert(span{s, s + r.Intn(100)})
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		it.Stabbing(Int(i%(benchmarkTreeSize*10)), func(Interval) bool { return true })
+	}
+}
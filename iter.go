@@ -0,0 +1,88 @@
+// Copyright 2014 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package btree
+
+import "iter"
+
+// The methods below return the same sequences of items as the Ascend and
+// Descend methods, as iterators for use with range.  Breaking out of the loop
+// stops the scan.  As with the callbacks, the tree must not be modified while
+// the loop runs.
+
+// All returns an iterator over every item in the tree, in ascending order,
+// like Ascend.
+func (t *BTree) All() iter.Seq[Item] {
+	return func(yield func(Item) bool) { t.Ascend(yield) }
+}
+
+// Backward returns an iterator over every item in the tree, in descending
+// order, like Descend.
+func (t *BTree) Backward() iter.Seq[Item] {
+	return func(yield func(Item) bool) { t.Descend(yield) }
+}
+
+// Range returns an iterator over the items in the range [greaterOrEqual,
+// lessThan), in ascending order, like AscendRange.
+func (t *BTree) Range(greaterOrEqual, lessThan Item) iter.Seq[Item] {
+	return func(yield func(Item) bool) { t.AscendRange(greaterOrEqual, lessThan, yield) }
+}
+
+// RangeDesc returns an iterator over the items in the range [lessOrEqual,
+// greaterThan), in descending order, like DescendRange.
+func (t *BTree) RangeDesc(lessOrEqual, greaterThan Item) iter.Seq[Item] {
+	return func(yield func(Item) bool) { t.DescendRange(lessOrEqual, greaterThan, yield) }
+}
+
+// From returns an iterator over the items greater than or equal to pivot, in
+// ascending order, like AscendGreaterOrEqual.
+func (t *BTree) From(pivot Item) iter.Seq[Item] {
+	return func(yield func(Item) bool) { t.AscendGreaterOrEqual(pivot, yield) }
+}
+
+// Before returns an iterator over the items less than pivot, in ascending
+// order, like AscendLessThan.
+func (t *BTree) Before(pivot Item) iter.Seq[Item] {
+	return func(yield func(Item) bool) { t.AscendLessThan(pivot, yield) }
+}
+
+// All is like BTree.All.
+func (t *BTreeG[T]) All() iter.Seq[T] {
+	return func(yield func(T) bool) { t.Ascend(yield) }
+}
+
+// Backward is like BTree.Backward.
+func (t *BTreeG[T]) Backward() iter.Seq[T] {
+	return func(yield func(T) bool) { t.Descend(yield) }
+}
+
+// Range is like BTree.Range.
+func (t *BTreeG[T]) Range(greaterOrEqual, lessThan T) iter.Seq[T] {
+	return func(yield func(T) bool) { t.AscendRange(greaterOrEqual, lessThan, yield) }
+}
+
+// RangeDesc is like BTree.RangeDesc.
+func (t *BTreeG[T]) RangeDesc(lessOrEqual, greaterThan T) iter.Seq[T] {
+	return func(yield func(T) bool) { t.DescendRange(lessOrEqual, greaterThan, yield) }
+}
+
+// From is like BTree.From.
+func (t *BTreeG[T]) From(pivot T) iter.Seq[T] {
+	return func(yield func(T) bool) { t.AscendGreaterOrEqual(pivot, yield) }
+}
+
+// Before is like BTree.Before.
+func (t *BTreeG[T]) Before(pivot T) iter.Seq[T] {
+	return func(yield func(T) bool) { t.AscendLessThan(pivot, yield) }
+}
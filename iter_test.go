@@ -0,0 +1,118 @@
+// Copyright 2014 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package btree
+
+import (
+	"iter"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+// collect gathers the items passed to an ItemIterator.
+func collect(scan func(ItemIterator)) (out []Item) {
+	scan(func(a Item) bool {
+		out = append(out, a)
+		return true
+	})
+	return out
+}
+
+func TestIterSeq(t *testing.T) {
+	tr := New(2)
+	for _, v := range perm(100) {
+		tr.ReplaceOrInsert(v)
+	}
+	for _, p := range []struct{ lo, hi Int }{{40, 60}, {-1, 50}, {50, 200}, {60, 40}, {50, 50}, {-10, -1}} {
+		for _, test := range []struct {
+			name string
+			seq  iter.Seq[Item]
+			want []Item
+		}{
+			{"All", tr.All(), collect(tr.Ascend)},
+			{"Backward", tr.Backward(), collect(tr.Descend)},
+			{"Range", tr.Range(p.lo, p.hi), collect(func(f ItemIterator) { tr.AscendRange(p.lo, p.hi, f) })},
+			{"RangeDesc", tr.RangeDesc(p.hi, p.lo), collect(func(f ItemIterator) { tr.DescendRange(p.hi, p.lo, f) })},
+			{"From", tr.From(p.lo), collect(func(f ItemIterator) { tr.AscendGreaterOrEqual(p.lo, f) })},
+			{"Before", tr.Before(p.hi), collect(func(f ItemIterator) { tr.AscendLessThan(p.hi, f) })},
+		} {
+			if got := slices.Collect(test.seq); !sameItems(got, test.want) {
+				t.Fatalf("%s(%v, %v):\n got: %v\nwant: %v", test.name, p.lo, p.hi, got, test.want)
+			}
+		}
+	}
+	if got, want := slices.Collect(tr.RangeDesc(Int(60), Int(40))), rangrev(100)[39:59]; !reflect.DeepEqual(got, want) {
+		t.Fatalf("RangeDesc:\n got: %v\nwant: %v", got, want)
+	}
+}
+
+func TestIterSeqBreak(t *testing.T) {
+	tr := New(*btreeDegree)
+	for _, v := range perm(100) {
+		tr.ReplaceOrInsert(v)
+	}
+	var got []Item
+	for a := range tr.RangeDesc(Int(60), Int(40)) {
+		if a.(Int) < 50 {
+			break
+		}
+		got = append(got, a)
+	}
+	if want := rangrev(100)[39:50]; !reflect.DeepEqual(got, want) {
+		t.Fatalf("RangeDesc with break:\n got: %v\nwant: %v", got, want)
+	}
+	got = got[:0]
+	for a := range tr.All() {
+		if a.(Int) == 10 {
+			break
+		}
+		got = append(got, a)
+	}
+	if want := rang(10); !reflect.DeepEqual(got, want) {
+		t.Fatalf("All with break:\n got: %v\nwant: %v", got, want)
+	}
+}
+
+func TestIterSeqG(t *testing.T) {
+	tr := NewG[int](*btreeDegree, Less[int]())
+	for i := 0; i < 100; i++ {
+		tr.ReplaceOrInsert(i)
+	}
+	if got := slices.Collect(tr.Range(10, 20)); !reflect.DeepEqual(got, intRange(20)[10:]) {
+		t.Fatalf("Range: got %v", got)
+	}
+	if got := slices.Collect(tr.RangeDesc(20, 10)); !reflect.DeepEqual(got, intRangeRev(21)[:10]) {
+		t.Fatalf("RangeDesc: got %v", got)
+	}
+	if got := slices.Collect(tr.From(90)); !reflect.DeepEqual(got, intRange(100)[90:]) {
+		t.Fatalf("From: got %v", got)
+	}
+	if got := slices.Collect(tr.Before(5)); !reflect.DeepEqual(got, intRange(5)) {
+		t.Fatalf("Before: got %v", got)
+	}
+	if got := slices.Collect(tr.Backward()); !reflect.DeepEqual(got, intRangeRev(100)) {
+		t.Fatalf("Backward: got %v", got)
+	}
+	var got []int
+	for v := range tr.All() {
+		if v == 3 {
+			break
+		}
+		got = append(got, v)
+	}
+	if !reflect.DeepEqual(got, intRange(3)) {
+		t.Fatalf("All with break: got %v", got)
+	}
+}
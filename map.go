@@ -0,0 +1,136 @@
+// Copyright 2014 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package btree
+
+import "iter"
+
+// Map is an ordered map from keys of type K to values of type V.
+//
+// It is a BTreeG of key/value entries ordered by key alone, so each node
+// holds its keys and values side by side, with no allocation per entry.
+//
+// Write operations are not safe for concurrent mutation by multiple
+// goroutines, but Read operations are.
+type Map[K, V any] struct {
+	t *BTreeG[mapEntry[K, V]]
+}
+
+// mapEntry is an entry of a Map.
+type mapEntry[K, V any] struct {
+	key   K
+	value V
+}
+
+// NewMap creates a new, empty Map with the given degree, whose keys are
+// ordered by less.
+func NewMap[K, V any](degree int, less LessFunc[K]) *Map[K, V] {
+	return &Map[K, V]{t: NewG(degree, func(a, b mapEntry[K, V]) bool {
+		return less(a.key, b.key)
+	})}
+}
+
+// NewOrderedMap creates a new, empty Map with the given degree, whose keys
+// are ordered by the '<' operator.
+func NewOrderedMap[K Ordered, V any](degree int) *Map[K, V] {
+	return NewMap[K, V](degree, Less[K]())
+}
+
+// Set sets the value for key.  If the map already held a value for key, it
+// is replaced, and returned with replaced set to true.
+func (m *Map[K, V]) Set(key K, value V) (old V, replaced bool) {
+	e, replaced := m.t.ReplaceOrInsert(mapEntry[K, V]{key, value})
+	return e.value, replaced
+}
+
+// Get returns the value for key, and whether there is one.
+func (m *Map[K, V]) Get(key K) (V, bool) {
+	e, ok := m.t.Get(mapEntry[K, V]{key: key})
+	return e.value, ok
+}
+
+// Has returns true if the map holds a value for key.
+func (m *Map[K, V]) Has(key K) bool {
+	return m.t.Has(mapEntry[K, V]{key: key})
+}
+
+// Delete removes key from the map, returning its value, if there was one.
+func (m *Map[K, V]) Delete(key K) (V, bool) {
+	e, ok := m.t.Delete(mapEntry[K, V]{key: key})
+	return e.value, ok
+}
+
+// Min returns the smallest key in the map and its value, or false if the map
+// is empty.
+func (m *Map[K, V]) Min() (K, V, bool) {
+	e, ok := m.t.Min()
+	return e.key, e.value, ok
+}
+
+// Max returns the largest key in the map and its value, or false if the map
+// is empty.
+func (m *Map[K, V]) Max() (K, V, bool) {
+	e, ok := m.t.Max()
+	return e.key, e.value, ok
+}
+
+// Len returns the number of keys in the map.
+func (m *Map[K, V]) Len() int {
+	return m.t.Len()
+}
+
+// Clone clones the map, lazily, as BTree.Clone does.
+func (m *Map[K, V]) Clone() *Map[K, V] {
+	return &Map[K, V]{t: m.t.Clone()}
+}
+
+// All returns an iterator over the map's keys and values, in ascending key
+// order.
+func (m *Map[K, V]) All() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		m.t.Ascend(func(e mapEntry[K, V]) bool { return yield(e.key, e.value) })
+	}
+}
+
+// Backward returns an iterator over the map's keys and values, in descending
+// key order.
+func (m *Map[K, V]) Backward() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		m.t.Descend(func(e mapEntry[K, V]) bool { return yield(e.key, e.value) })
+	}
+}
+
+// Range returns an iterator over the keys in the range [greaterOrEqual,
+// lessThan) and their values, in ascending key order.
+func (m *Map[K, V]) Range(greaterOrEqual, lessThan K) iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		m.t.AscendRange(mapEntry[K, V]{key: greaterOrEqual}, mapEntry[K, V]{key: lessThan}, func(e mapEntry[K, V]) bool {
+			return yield(e.key, e.value)
+		})
+	}
+}
+
+// Keys returns an iterator over the map's keys, in ascending order.
+func (m *Map[K, V]) Keys() iter.Seq[K] {
+	return func(yield func(K) bool) {
+		m.t.Ascend(func(e mapEntry[K, V]) bool { return yield(e.key) })
+	}
+}
+
+// Values returns an iterator over the map's values, in ascending key order.
+func (m *Map[K, V]) Values() iter.Seq[V] {
+	return func(yield func(V) bool) {
+		m.t.Ascend(func(e mapEntry[K, V]) bool { return yield(e.value) })
+	}
+}
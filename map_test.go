@@ -0,0 +1,120 @@
+// Copyright 2014 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package btree
+
+import (
+	"maps"
+	"math/rand"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestMap(t *testing.T) {
+	m := NewOrderedMap[int, string](*btreeDegree)
+	if _, _, ok := m.Min(); ok {
+		t.Fatal("empty map has a minimum")
+	}
+	want := map[int]string{}
+	for _, i := range rand.Perm(1000) {
+		v := strings.Repeat("x", i%7)
+		if _, replaced := m.Set(i, v); replaced {
+			t.Fatalf("Set(%d) replaced a value", i)
+		}
+		want[i] = v
+	}
+	if old, replaced := m.Set(5, "five"); !replaced || old != want[5] {
+		t.Fatalf("Set(5): got %q, %v", old, replaced)
+	}
+	want[5] = "five"
+	for i := 0; i < 1000; i += 2 {
+		if v, ok := m.Delete(i); !ok || v != want[i] {
+			t.Fatalf("Delete(%d): got %q, %v", i, v, ok)
+		}
+		delete(want, i)
+	}
+	if _, ok := m.Delete(0); ok {
+		t.Fatal("Delete of a missing key succeeded")
+	}
+	if v, ok := m.Get(5); !ok || v != "five" {
+		t.Fatalf("Get(5): got %q, %v", v, ok)
+	}
+	if _, ok := m.Get(4); ok || m.Has(4) || !m.Has(7) {
+		t.Fatal("Get found a deleted key")
+	}
+	if k, v, ok := m.Min(); !ok || k != 1 || v != want[1] {
+		t.Fatalf("Min: got %d, %q", k, v)
+	}
+	if k, _, ok := m.Max(); !ok || k != 999 {
+		t.Fatalf("Max: got %d", k)
+	}
+	if got := maps.Collect(m.All()); !reflect.DeepEqual(got, want) || m.Len() != len(want) {
+		t.Fatalf("All: got %d entries, want %d", len(got), len(want))
+	}
+	keys := slices.Collect(m.Keys())
+	if !slices.IsSorted(keys) || len(keys) != len(want) {
+		t.Fatalf("Keys not sorted")
+	}
+	for i, v := range slices.Collect(m.Values()) {
+		if v != want[keys[i]] {
+			t.Fatalf("Values[%d] = %q, want %q", i, v, want[keys[i]])
+		}
+	}
+	var back []int
+	for k := range m.Backward() {
+		back = append(back, k)
+	}
+	slices.Reverse(back)
+	if !reflect.DeepEqual(back, keys) {
+		t.Fatalf("Backward doesn't mirror All")
+	}
+	var got []int
+	for k, v := range m.Range(10, 20) {
+		if v != want[k] {
+			t.Fatalf("Range: %d maps to %q, want %q", k, v, want[k])
+		}
+		got = append(got, k)
+	}
+	if want := []int{11, 13, 15, 17, 19}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Range: got %v, want %v", got, want)
+	}
+}
+
+func TestMapClone(t *testing.T) {
+	m := NewMap[string, int](2, func(a, b string) bool { return a < b })
+	m.Set("a", 1)
+	m.Set("b", 2)
+	c := m.Clone()
+	c.Set("a", 10)
+	c.Delete("b")
+	if v, _ := m.Get("a"); v != 1 || !m.Has("b") {
+		t.Fatal("writes to clone changed the original")
+	}
+	if v, _ := c.Get("a"); v != 10 || c.Has("b") {
+		t.Fatal("clone missing its own writes")
+	}
+}
+
+func BenchmarkMapGet(b *testing.B) {
+	m := NewOrderedMap[int, int](*btreeDegree)
+	for _, i := range rand.Perm(benchmarkTreeSize) {
+		m.Set(i, i)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		m.Get(i % benchmarkTreeSize)
+	}
+}
@@ -0,0 +1,234 @@
+// Copyright 2014 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package btree
+
+import (
+	"sort"
+	"sync/atomic"
+)
+
+// MVCCTree is a BTree that keeps every committed version of its contents,
+// so that they can be read as they were at any version not yet pruned.
+//
+// Versions share nodes through copy-on-write, as clones do: each version
+// costs only the nodes written since the one before it.  Prune releases old
+// versions, returning the nodes no other version uses to the freelist.
+//
+// An MVCCTree is not safe for concurrent use.
+type MVCCTree struct {
+	head     *BTree
+	versions []mvccVersion
+	latest   uint64
+	// pinned is one past the latest released version that was cloned.  Any
+	// node still in the tree that was written for a version before pinned
+	// is also in that cloned version, so Prune never frees it.
+	pinned uint64
+}
+
+// mvccVersion is a committed version of an MVCCTree.
+type mvccVersion struct {
+	version uint64
+	snap    *MVCCSnapshot
+	// cow is the context the head was written with before this version was
+	// committed, which owns the nodes that first appeared in it.
+	cow *copyOnWriteContext
+	// cloned is true if the version's nodes were shared with a tree outside
+	// the MVCCTree while it was being written, by Update's fn cloning it.
+	cloned bool
+}
+
+// escaped reports whether v's nodes may be in use by a tree made with Clone.
+func (v *mvccVersion) escaped() bool {
+	return v.cloned || v.snap.cloned.Load()
+}
+
+// MVCCSnapshot is a read-only view of an MVCCTree as of one version.  It
+// offers the methods of a Snapshot, and is safe for concurrent use in the same
+// way.
+type MVCCSnapshot struct {
+	*Snapshot
+	// cloned is set once Clone has shared the snapshot's nodes with a
+	// writable tree, so that Prune doesn't free them.
+	cloned atomic.Bool
+}
+
+// Clone is like Snapshot.Clone.  The nodes the new tree shares with the
+// snapshot are never returned to the freelist by Prune, even after the
+// snapshot's version is released.
+func (s *MVCCSnapshot) Clone() *BTree {
+	s.cloned.Store(true)
+	return s.Snapshot.Clone()
+}
+
+// NewMVCC creates a new, empty MVCCTree with the given degree.  Its initial
+// contents, before the first commit, are version 0.
+func NewMVCC(degree int) *MVCCTree {
+	return NewMVCCWithFreeList(degree, NewFreeList(DefaultFreeListSize))
+}
+
+// NewMVCCWithFreeList creates a new, empty MVCCTree that uses the given node
+// free list.
+func NewMVCCWithFreeList(degree int, f *FreeList) *MVCCTree {
+	m := &MVCCTree{head: NewWithFreeList(degree, f)}
+	m.commit()
+	return m
+}
+
+// Update calls fn with the tree's working copy, then commits the result as a
+// new version and returns its number.  Versions are numbered in increasing
+// order.
+//
+// fn must only change t through its own methods, and must not keep t or use
+// it after returning.  In particular, it must not give t nodes from other
+// trees, as Join and UnionWith do, since Prune relies on each node being
+// written by only one version.  fn may Clone t, though Prune then never frees
+// the nodes the clone shares with the tree.
+func (m *MVCCTree) Update(fn func(t *BTree)) uint64 {
+	cow := m.head.cow
+	fn(m.head)
+	if m.head.cow != cow {
+		// Only Clone moves t to a new context.  The clone has the previous
+		// version's nodes, and maybe some of this one's.
+		m.versions[len(m.versions)-1].cloned = true
+		v := m.commit()
+		m.versions[len(m.versions)-1].cloned = true
+		return v
+	}
+	return m.commit()
+}
+
+// commit records the head as a new version.
+func (m *MVCCTree) commit() uint64 {
+	cow := m.head.cow
+	// Clone moves the head to a new context, so the nodes written so far
+	// are frozen, and are copied before the head writes to them again.
+	snap := m.head.Clone()
+	if len(m.versions) > 0 {
+		m.latest++
+	}
+	m.versions = append(m.versions, mvccVersion{version: m.latest, snap: &MVCCSnapshot{Snapshot: &Snapshot{t: snap}}, cow: cow})
+	// The head's new context writes the nodes of the next version.
+	m.head.cow.gen = m.latest + 1
+	return m.latest
+}
+
+// Version returns the number of the latest version.
+func (m *MVCCTree) Version() uint64 {
+	return m.latest
+}
+
+// At returns a read-only view of the tree as of the given version: that is,
+// of the latest version no newer than it.  It returns nil if version is older
+// than every version retained by Prune.  The Snapshot must not be used after
+// its version is pruned.
+func (m *MVCCTree) At(version uint64) *MVCCSnapshot {
+	i := sort.Search(len(m.versions), func(i int) bool {
+		return m.versions[i].version > version
+	})
+	if i == 0 {
+		return nil
+	}
+	return m.versions[i-1].snap
+}
+
+// GetAt looks for the key item in the tree as of the given version,
+// returning it, or nil if it wasn't there or the version has been pruned.
+func (m *MVCCTree) GetAt(key Item, version uint64) Item {
+	if s := m.At(version); s != nil {
+		return s.Get(key)
+	}
+	return nil
+}
+
+// AscendAt calls the iterator for every item in the tree as of the given
+// version, in order, until iterator returns false.  It does nothing if the
+// version has been pruned.
+func (m *MVCCTree) AscendAt(version uint64, iterator ItemIterator) {
+	if s := m.At(version); s != nil {
+		s.Ascend(iterator)
+	}
+}
+
+// Prune releases every version older than olderThan, other than the latest
+// version, which is always kept.  It returns the number of versions released.
+//
+// Nodes that only released versions used are returned to the freelist.
+// Finding them doesn't require visiting every node: a node leaves the tree
+// for good once a version no longer has it, and the next version's new nodes
+// point to exactly those old nodes it still has, so Prune only visits the
+// nodes that changed between each released version and the next.
+//
+// A version whose Snapshot was cloned, or which Update's fn cloned, may share
+// its nodes with the clone for as long as the clone lives.  Prune never frees
+// the nodes of such a version, nor those of any version before it, since the
+// only ones later versions still have are shared with it too.  They are left
+// to the garbage collector instead, once nothing uses them.  Keeping track of
+// this costs Prune no memory, however many versions are cloned.
+func (m *MVCCTree) Prune(olderThan uint64) int {
+	n := sort.Search(len(m.versions), func(i int) bool {
+		return m.versions[i].version >= olderThan
+	})
+	if n == len(m.versions) {
+		n--
+	}
+	for i := 0; i < n; i++ {
+		root := m.versions[i].snap.t.root
+		if m.versions[i].escaped() {
+			m.pinned = m.versions[i].version + 1
+		} else if root != nil {
+			next := m.versions[i+1]
+			kept := make(map[*node]bool)
+			keptNodes(next.snap.t.root, next.cow, kept)
+			freeUnkept(root, kept, m.pinned)
+		}
+		m.versions[i] = mvccVersion{}
+	}
+	m.versions = append(m.versions[:0], m.versions[n:]...)
+	return n
+}
+
+// keptNodes adds to kept each node that a version took from the version
+// before it: those directly under the nodes it wrote itself, which are owned
+// by cow.
+func keptNodes(n *node, cow *copyOnWriteContext, kept map[*node]bool) {
+	if n == nil {
+		return
+	}
+	if n.cow != cow {
+		kept[n] = true
+		return
+	}
+	for _, child := range n.children {
+		keptNodes(child, cow, kept)
+	}
+}
+
+// freeUnkept returns every node in the subtree rooted at n to the freelist,
+// apart from kept subtrees and nodes written for versions before pinned.  It
+// returns false once the freelist is full.
+func freeUnkept(n *node, kept map[*node]bool, pinned uint64) bool {
+	if kept[n] {
+		return true
+	}
+	for _, child := range n.children {
+		if !freeUnkept(child, kept, pinned) {
+			return false
+		}
+	}
+	if n.cow.gen < pinned {
+		return true
+	}
+	return n.cow.freeNode(n) != ftFreelistFull
+}
@@ -0,0 +1,220 @@
+// Copyright 2014 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package btree
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestMVCC(t *testing.T) {
+	f := NewFreeList(10000)
+	m := NewMVCCWithFreeList(3, f)
+	if m.Version() != 0 || m.At(0).Len() != 0 {
+		t.Fatalf("new tree: version %d, len %d", m.Version(), m.At(0).Len())
+	}
+	r := rand.New(rand.NewSource(1))
+	model := map[int]bool{}
+	var want [][]Item // want[v] holds the items at version v
+	want = append(want, nil)
+	for v := 1; v <= 200; v++ {
+		got := m.Update(func(tr *BTree) {
+			for i := 0; i < 20; i++ {
+				k := r.Intn(500)
+				if r.Intn(3) == 0 {
+					tr.Delete(Int(k))
+					delete(model, k)
+				} else {
+					tr.ReplaceOrInsert(Int(k))
+					model[k] = true
+				}
+			}
+		})
+		if got != uint64(v) {
+			t.Fatalf("Update returned version %d, want %d", got, v)
+		}
+		var items []Item
+		for k := 0; k < 500; k++ {
+			if model[k] {
+				items = append(items, Int(k))
+			}
+		}
+		want = append(want, items)
+	}
+	check := func(from int) {
+		t.Helper()
+		for v := from; v < len(want); v++ {
+			var got []Item
+			m.AscendAt(uint64(v), func(a Item) bool {
+				got = append(got, a)
+				return true
+			})
+			if !sameItems(got, want[v]) {
+				t.Fatalf("version %d:\n got: %v\nwant: %v", v, got, want[v])
+			}
+			if err := m.At(uint64(v)).t.Validate(); err != nil {
+				t.Fatalf("version %d: %v", v, err)
+			}
+		}
+	}
+	check(0)
+	if got := m.GetAt(want[50][0], 50); got != want[50][0] {
+		t.Fatalf("GetAt: got %v, want %v", got, want[50][0])
+	}
+
+	if n := m.Prune(100); n != 100 {
+		t.Fatalf("Prune released %d versions, want 100", n)
+	}
+	if m.At(99) != nil || m.GetAt(Int(1), 99) != nil {
+		t.Fatalf("pruned version still readable")
+	}
+	freed, _ := f.stats()
+	if freed == 0 {
+		t.Fatalf("Prune freed no nodes")
+	}
+	check(100)
+
+	// Writes reusing the freed nodes mustn't disturb the remaining versions.
+	m.Update(func(tr *BTree) {
+		for i := 0; i < 500; i++ {
+			tr.ReplaceOrInsert(Int(i))
+		}
+	})
+	check(100)
+
+	if n := m.Prune(1000); n != 101 {
+		t.Fatalf("Prune released %d versions, want 101", n)
+	}
+	if m.At(m.Version()) == nil || !reflect.DeepEqual(all(m.At(m.Version()).t), rang(500)) {
+		t.Fatalf("Prune dropped the latest version")
+	}
+}
+
+// TestMVCCPruneFreesUnused checks that Prune frees exactly the nodes no
+// remaining version uses.
+func TestMVCCPruneFreesUnused(t *testing.T) {
+	f := NewFreeList(10000)
+	m := NewMVCCWithFreeList(2, f)
+	r := rand.New(rand.NewSource(2))
+	for v := 0; v < 50; v++ {
+		m.Update(func(tr *BTree) {
+			for i := 0; i < 10; i++ {
+				tr.ReplaceOrInsert(Int(r.Intn(1000)))
+				tr.Delete(Int(r.Intn(1000)))
+			}
+		})
+	}
+	reachable := func(from int) map[*node]bool {
+		seen := map[*node]bool{}
+		var walk func(n *node)
+		walk = func(n *node) {
+			if n == nil || seen[n] {
+				return
+			}
+			seen[n] = true
+			for _, c := range n.children {
+				walk(c)
+			}
+		}
+		for _, v := range m.versions[from:] {
+			walk(v.snap.t.root)
+		}
+		return seen
+	}
+	before := len(reachable(0))
+	after := len(reachable(30))
+	m.Prune(30)
+	if freed, _ := f.stats(); freed != before-after {
+		t.Fatalf("Prune freed %d nodes, want %d", freed, before-after)
+	}
+}
+
+// TestMVCCPruneClone checks that Prune doesn't free the nodes of a pruned
+// version still used by a clone of it.
+func TestMVCCPruneClone(t *testing.T) {
+	for _, inUpdate := range []bool{false, true} {
+		m := NewMVCCWithFreeList(3, NewFreeList(10000))
+		var keep *BTree
+		v1 := m.Update(func(tr *BTree) {
+			for i := 0; i < 100; i++ {
+				tr.ReplaceOrInsert(Int(i))
+			}
+			if inUpdate {
+				keep = tr.Clone()
+			}
+		})
+		if !inUpdate {
+			keep = m.At(v1).Clone()
+		}
+		m.Update(func(tr *BTree) {
+			for i := 0; i < 100; i++ {
+				tr.Delete(Int(i))
+			}
+		})
+		m.Prune(m.Version())
+		// Writes that would reuse any wrongly freed nodes.
+		for v := 0; v < 10; v++ {
+			m.Update(func(tr *BTree) {
+				for i := 0; i < 100; i++ {
+					tr.ReplaceOrInsert(Int(1000 + v*100 + i))
+				}
+			})
+			m.Prune(m.Version())
+		}
+		if keep.Len() != 100 {
+			t.Fatalf("inUpdate %v: clone has %d items, want 100", inUpdate, keep.Len())
+		}
+		if got := all(keep); !reflect.DeepEqual(got, rang(100)) {
+			t.Fatalf("inUpdate %v: clone changed: got %v", inUpdate, got)
+		}
+		if err := keep.Validate(); err != nil {
+			t.Fatalf("inUpdate %v: %v", inUpdate, err)
+		}
+	}
+}
+
+// TestMVCCPruneAfterClone checks that a cloned version only stops Prune from
+// freeing the nodes of versions up to it, not those written afterwards.
+func TestMVCCPruneAfterClone(t *testing.T) {
+	f := NewFreeList(10000)
+	m := NewMVCCWithFreeList(3, f)
+	v1 := m.Update(func(tr *BTree) {
+		for i := 0; i < 100; i++ {
+			tr.ReplaceOrInsert(Int(i))
+		}
+	})
+	keep := m.At(v1).Clone()
+	for v := 0; v < 20; v++ {
+		m.Update(func(tr *BTree) {
+			for i := 100; i < 200; i++ {
+				tr.ReplaceOrInsert(Int(i))
+			}
+		})
+		m.Update(func(tr *BTree) {
+			tr.DeleteRange(Int(100), Int(200))
+		})
+		m.Prune(m.Version())
+	}
+	if freed, _ := f.stats(); freed == 0 {
+		t.Fatalf("Prune freed none of the nodes written after the cloned version")
+	}
+	if got := all(keep); !reflect.DeepEqual(got, rang(100)) {
+		t.Fatalf("clone changed: got %v", got)
+	}
+	if err := keep.Validate(); err != nil {
+		t.Fatal(err)
+	}
+}
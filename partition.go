@@ -0,0 +1,93 @@
+// Copyright 2014 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package btree
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Range is the key range [GreaterOrEqual, LessThan).  A nil bound leaves that
+// end of the range open, as it does when passed to AscendRange.
+type Range struct {
+	GreaterOrEqual, LessThan Item
+}
+
+// Partition splits the keys of t into at most n disjoint ranges which, in
+// order, cover every item in the tree, and hold roughly t.Len()/n items each.
+// The first range has no lower bound and the last has no upper bound, so the
+// ranges also cover keys inserted after the call.
+//
+// The bounds are found with the item counts cached in each node, which costs
+// one descent from the root for each range, independent of how many items the
+// ranges hold.  In a tree created by NewMulti, equivalent items always share a
+// range, so long runs of them can leave the ranges uneven, or fewer than n.
+//
+// Partition returns nil if t is empty, and a single open range if n <= 1.
+func (t *BTree) Partition(n int) []Range {
+	if t.length == 0 {
+		return nil
+	}
+	if n < 1 {
+		n = 1
+	} else if n > t.length {
+		n = t.length
+	}
+	out := make([]Range, 0, n)
+	var lo Item
+	for i := 1; i < n; i++ {
+		hi := t.root.getAt(i * t.length / n)
+		if lo != nil && !lo.Less(hi) {
+			// A run of equivalent items spans this bound.
+			continue
+		}
+		out = append(out, Range{lo, hi})
+		lo = hi
+	}
+	return append(out, Range{lo, nil})
+}
+
+// ParallelAscend calls fn for every item in the tree, using up to workers
+// goroutines, each of which ascends one range from t.Partition(workers).
+// Items within a range are visited in order, but ranges are visited
+// concurrently, so fn must be safe to call from several goroutines at once.
+// Once any call of fn returns false, the other goroutines stop at their next
+// item.  ParallelAscend returns when all of them have finished.
+//
+// Like any other read, ParallelAscend must not run concurrently with a write
+// to t.
+func (t *BTree) ParallelAscend(workers int, fn ItemIterator) {
+	var (
+		stopped atomic.Bool
+		wg      sync.WaitGroup
+	)
+	for _, r := range t.Partition(workers) {
+		wg.Add(1)
+		go func(r Range) {
+			defer wg.Done()
+			t.AscendRange(r.GreaterOrEqual, r.LessThan, func(item Item) bool {
+				if stopped.Load() {
+					return false
+				}
+				if !fn(item) {
+					stopped.Store(true)
+					return false
+				}
+				return true
+			})
+		}(r)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,142 @@
+// Copyright 2014 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package btree
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// checkPartition checks that ranges are in order and together cover every
+// item of tr exactly once, returning the number of items in each.
+func checkPartition(t *testing.T, tr *BTree, ranges []Range) []int {
+	t.Helper()
+	if len(ranges) == 0 {
+		t.Fatalf("no ranges")
+	}
+	if ranges[0].GreaterOrEqual != nil || ranges[len(ranges)-1].LessThan != nil {
+		t.Fatalf("outer bounds are not open: %v", ranges)
+	}
+	var got []Item
+	counts := make([]int, len(ranges))
+	for i, r := range ranges {
+		if i > 0 && r.GreaterOrEqual != ranges[i-1].LessThan {
+			t.Fatalf("range %d starts at %v, but range %d ends at %v", i, r.GreaterOrEqual, i-1, ranges[i-1].LessThan)
+		}
+		tr.AscendRange(r.GreaterOrEqual, r.LessThan, func(item Item) bool {
+			got = append(got, item)
+			counts[i]++
+			return true
+		})
+	}
+	if want := all(tr); !reflect.DeepEqual(got, want) {
+		t.Fatalf("ranges cover %d items, want %d", len(got), len(want))
+	}
+	return counts
+}
+
+func TestPartition(t *testing.T) {
+	if got := New(*btreeDegree).Partition(4); got != nil {
+		t.Fatalf("empty tree: got %v", got)
+	}
+	for _, size := range []int{1, 3, 10, 1000, 10007} {
+		tr := New(*btreeDegree)
+		for _, v := range perm(size) {
+			tr.ReplaceOrInsert(v)
+		}
+		for _, n := range []int{-1, 0, 1, 2, 7, 64} {
+			ranges := tr.Partition(n)
+			want := n
+			if want < 1 {
+				want = 1
+			}
+			if want > size {
+				want = size
+			}
+			if len(ranges) != want {
+				t.Fatalf("size %d: Partition(%d) returned %d ranges", size, n, len(ranges))
+			}
+			for i, c := range checkPartition(t, tr, ranges) {
+				if c < size/want || c > size/want+1 {
+					t.Errorf("size %d: Partition(%d): range %d holds %d items", size, n, i, c)
+				}
+			}
+		}
+	}
+}
+
+func TestPartitionMulti(t *testing.T) {
+	tr := NewMulti(*btreeDegree)
+	for i := 0; i < 1000; i++ {
+		tr.InsertNoReplace(Int(i % 10))
+	}
+	for i := 0; i < 100; i++ {
+		tr.InsertNoReplace(Int(5))
+	}
+	for _, n := range []int{2, 10, 50} {
+		ranges := tr.Partition(n)
+		if len(ranges) > n {
+			t.Fatalf("Partition(%d) returned %d ranges", n, len(ranges))
+		}
+		checkPartition(t, tr, ranges)
+	}
+}
+
+func TestParallelAscend(t *testing.T) {
+	tr := New(*btreeDegree)
+	for _, v := range perm(10000) {
+		tr.ReplaceOrInsert(v)
+	}
+	for _, workers := range []int{1, 4, 16} {
+		var mu sync.Mutex
+		seen := make([]int, tr.Len())
+		tr.ParallelAscend(workers, func(item Item) bool {
+			mu.Lock()
+			seen[item.(Int)]++
+			mu.Unlock()
+			return true
+		})
+		for i, c := range seen {
+			if c != 1 {
+				t.Fatalf("%d workers: item %d visited %d times", workers, i, c)
+			}
+		}
+	}
+
+	var calls atomic.Int64
+	tr.ParallelAscend(4, func(item Item) bool {
+		return calls.Add(1) < 10
+	})
+	if got := calls.Load(); got < 10 || got > 10+4 {
+		t.Errorf("ParallelAscend made %d calls after being stopped at 10", got)
+	}
+}
+
+func BenchmarkParallelAscend(b *testing.B) {
+	tr := New(*btreeDegree)
+	for _, v := range perm(benchmarkTreeSize) {
+		tr.ReplaceOrInsert(v)
+	}
+	for _, workers := range []int{1, 4, 16} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				tr.ParallelAscend(workers, func(Item) bool { return true })
+			}
+		})
+	}
+}
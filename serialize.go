@@ -0,0 +1,232 @@
+// Copyright 2014 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package btree
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+// The serialized form of a tree, as written by WriteTo, is:
+//
+//	magic    [4]byte  "BTRE"
+//	version  uint8    serialVersion
+//	flags    uint8    serialMulti if the tree was created by NewMulti
+//	count    uint64   number of items
+//	items    count times:
+//	  length uint32   length of the encoded item
+//	  data   [length]byte
+//	checksum uint32   CRC-32C of everything above
+//
+// All integers are big-endian.
+const (
+	serialMagic   = "BTRE"
+	serialVersion = 1
+	serialMulti   = 1 << 0
+	headerLen     = len(serialMagic) + 1 + 1 + 8
+)
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// ErrCorrupt is returned by ReadFrom when its input isn't a valid serialized
+// tree.
+var ErrCorrupt = errors.New("btree: corrupt serialized tree")
+
+// ItemCodec converts items to and from bytes, for WriteTo and ReadFrom.
+type ItemCodec interface {
+	// AppendItem appends the encoding of item to buf and returns the extended
+	// buffer.
+	AppendItem(buf []byte, item Item) ([]byte, error)
+	// DecodeItem decodes the item encoded in data.  data is only valid for the
+	// duration of the call, so the item must not refer to it.
+	DecodeItem(data []byte) (Item, error)
+}
+
+var (
+	// IntCodec encodes Int items as varints.
+	IntCodec ItemCodec = intCodec{}
+	// BytesCodec encodes Bytes items as themselves.
+	BytesCodec ItemCodec = bytesCodec{}
+)
+
+type intCodec struct{}
+
+func (intCodec) AppendItem(buf []byte, item Item) ([]byte, error) {
+	i, ok := item.(Int)
+	if !ok {
+		return buf, fmt.Errorf("btree: IntCodec can't encode %T", item)
+	}
+	return binary.AppendVarint(buf, int64(i)), nil
+}
+
+func (intCodec) DecodeItem(data []byte) (Item, error) {
+	v, n := binary.Varint(data)
+	if n <= 0 || n != len(data) {
+		return nil, fmt.Errorf("%w: bad Int encoding", ErrCorrupt)
+	}
+	return Int(v), nil
+}
+
+type bytesCodec struct{}
+
+func (bytesCodec) AppendItem(buf []byte, item Item) ([]byte, error) {
+	b, ok := item.(Bytes)
+	if !ok {
+		return buf, fmt.Errorf("btree: BytesCodec can't encode %T", item)
+	}
+	return append(buf, b...), nil
+}
+
+func (bytesCodec) DecodeItem(data []byte) (Item, error) {
+	b := make(Bytes, len(data))
+	copy(b, data)
+	return b, nil
+}
+
+// WriteTo writes the items of t to w, encoded with codec, returning the
+// number of bytes written.  The tree can be loaded again with ReadFrom.
+func (t *BTree) WriteTo(w io.Writer, codec ItemCodec) (int64, error) {
+	bw := bufio.NewWriter(w)
+	crc := crc32.New(crcTable)
+	out := io.MultiWriter(bw, crc)
+	var written int64
+	write := func(b []byte) error {
+		n, err := out.Write(b)
+		written += int64(n)
+		return err
+	}
+
+	buf := make([]byte, 0, 64)
+	buf = append(buf, serialMagic...)
+	buf = append(buf, serialVersion)
+	var flags byte
+	if t.multi {
+		flags |= serialMulti
+	}
+	buf = append(buf, flags)
+	buf = binary.BigEndian.AppendUint64(buf, uint64(t.Len()))
+	if err := write(buf); err != nil {
+		return written, err
+	}
+
+	var err error
+	t.Ascend(func(item Item) bool {
+		// Leave room for the length, and fill it in once the item is encoded.
+		buf, err = codec.AppendItem(append(buf[:0], 0, 0, 0, 0), item)
+		if err != nil {
+			return false
+		}
+		binary.BigEndian.PutUint32(buf, uint32(len(buf)-4))
+		err = write(buf)
+		return err == nil
+	})
+	if err != nil {
+		return written, err
+	}
+
+	buf = binary.BigEndian.AppendUint32(buf[:0], crc.Sum32())
+	n, err := bw.Write(buf)
+	written += int64(n)
+	if err != nil {
+		return written, err
+	}
+	return written, bw.Flush()
+}
+
+// ReadFrom reads a tree written by WriteTo from r, decoding its items with
+// codec, and returns it as a new B-Tree of the given degree.  The tree is
+// built bottom-up in O(n) time, as by BuildSorted.
+//
+// ReadFrom reads exactly up to the end of the serialized tree, making many
+// small reads; wrap r in a bufio.Reader if it isn't buffered.
+func ReadFrom(r io.Reader, codec ItemCodec, degree int) (*BTree, error) {
+	crc := crc32.New(crcTable)
+	in := io.TeeReader(r, crc)
+
+	var header [headerLen]byte
+	if _, err := io.ReadFull(in, header[:]); err != nil {
+		return nil, readError(err)
+	}
+	if string(header[:len(serialMagic)]) != serialMagic {
+		return nil, fmt.Errorf("%w: bad magic %q", ErrCorrupt, header[:len(serialMagic)])
+	}
+	if v := header[len(serialMagic)]; v != serialVersion {
+		return nil, fmt.Errorf("btree: unsupported serialization version %d", v)
+	}
+	flags := header[len(serialMagic)+1]
+	count := binary.BigEndian.Uint64(header[len(serialMagic)+2:])
+
+	b := NewBuilder(degree, DefaultFill, NewFreeList(DefaultFreeListSize))
+	b.t.multi = flags&serialMulti != 0
+	var buf []byte
+	for i := uint64(0); i < count; i++ {
+		var length [4]byte
+		if _, err := io.ReadFull(in, length[:]); err != nil {
+			return nil, readError(err)
+		}
+		var err error
+		if buf, err = readItem(in, buf[:0], binary.BigEndian.Uint32(length[:])); err != nil {
+			return nil, readError(err)
+		}
+		item, err := codec.DecodeItem(buf)
+		if err != nil {
+			return nil, err
+		}
+		if err := b.Add(item); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrCorrupt, err)
+		}
+	}
+
+	want := crc.Sum32()
+	var sum [4]byte
+	if _, err := io.ReadFull(r, sum[:]); err != nil {
+		return nil, readError(err)
+	}
+	if got := binary.BigEndian.Uint32(sum[:]); got != want {
+		return nil, fmt.Errorf("%w: checksum mismatch", ErrCorrupt)
+	}
+	return b.Build()
+}
+
+// readItem appends the next n bytes of r to buf.  The buffer grows as data
+// arrives, so a corrupt length can't make it allocate far more than the input
+// holds.
+func readItem(r io.Reader, buf []byte, n uint32) ([]byte, error) {
+	const chunk = 64 << 10
+	for remaining := int(n); remaining > 0; {
+		step := remaining
+		if step > chunk {
+			step = chunk
+		}
+		buf = append(buf, make([]byte, step)...)
+		if _, err := io.ReadFull(r, buf[len(buf)-step:]); err != nil {
+			return buf, err
+		}
+		remaining -= step
+	}
+	return buf, nil
+}
+
+// readError reports running out of input as corruption.
+func readError(err error) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return fmt.Errorf("%w: truncated", ErrCorrupt)
+	}
+	return err
+}
@@ -0,0 +1,140 @@
+// Copyright 2014 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package btree
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestWriteToReadFrom(t *testing.T) {
+	for _, n := range []int{0, 1, 10, 1000} {
+		tr := New(*btreeDegree)
+		for _, v := range perm(n) {
+			tr.ReplaceOrInsert(v)
+		}
+		tr.ReplaceOrInsert(Int(-1 << 40))
+		var buf bytes.Buffer
+		written, err := tr.WriteTo(&buf, IntCodec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if written != int64(buf.Len()) {
+			t.Errorf("WriteTo reported %d bytes, wrote %d", written, buf.Len())
+		}
+		got, err := ReadFrom(&buf, IntCodec, 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(all(got), all(tr)) {
+			t.Fatalf("n=%d: round trip changed the items", n)
+		}
+		if got.Len() > 0 {
+			checkShape(t, got, got.root, true)
+		}
+		if buf.Len() != 0 {
+			t.Errorf("ReadFrom left %d bytes unread", buf.Len())
+		}
+	}
+}
+
+func TestWriteToReadFromBytesMulti(t *testing.T) {
+	tr := NewMulti(*btreeDegree)
+	for i := 0; i < 500; i++ {
+		tr.InsertNoReplace(Bytes(fmt.Sprintf("key%03d", i%100)))
+	}
+	tr.InsertNoReplace(Bytes{})
+	var buf bytes.Buffer
+	if _, err := tr.WriteTo(&buf, BytesCodec); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadFrom(&buf, BytesCodec, *btreeDegree)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(all(got), all(tr)) {
+		t.Fatalf("round trip changed the items")
+	}
+	if c := got.Count(Bytes("key042")); c != 5 {
+		t.Errorf("Count after reload: got %d, want 5", c)
+	}
+	got.InsertNoReplace(Bytes("key042"))
+	if c := got.Count(Bytes("key042")); c != 6 {
+		t.Errorf("reloaded tree isn't a multiset: Count %d, want 6", c)
+	}
+}
+
+func TestReadFromCorrupt(t *testing.T) {
+	tr := New(*btreeDegree)
+	for _, v := range perm(100) {
+		tr.ReplaceOrInsert(v)
+	}
+	var buf bytes.Buffer
+	if _, err := tr.WriteTo(&buf, IntCodec); err != nil {
+		t.Fatal(err)
+	}
+	good := buf.Bytes()
+
+	flip := append([]byte(nil), good...)
+	flip[len(flip)/2] ^= 1
+	badMagic := append([]byte(nil), good...)
+	badMagic[0] = 'X'
+	for name, data := range map[string][]byte{
+		"empty":     nil,
+		"header":    good[:headerLen-1],
+		"truncated": good[:len(good)-1],
+		"bit flip":  flip,
+		"magic":     badMagic,
+	} {
+		if _, err := ReadFrom(bytes.NewReader(data), IntCodec, *btreeDegree); !errors.Is(err, ErrCorrupt) {
+			t.Errorf("%s: got error %v, want ErrCorrupt", name, err)
+		}
+	}
+
+	version := append([]byte(nil), good...)
+	version[len(serialMagic)] = serialVersion + 1
+	if _, err := ReadFrom(bytes.NewReader(version), IntCodec, *btreeDegree); err == nil || errors.Is(err, ErrCorrupt) {
+		t.Errorf("unknown version: got error %v", err)
+	}
+}
+
+func TestWriteToCodecError(t *testing.T) {
+	tr := New(*btreeDegree)
+	tr.ReplaceOrInsert(Int(1))
+	if _, err := tr.WriteTo(&bytes.Buffer{}, BytesCodec); err == nil {
+		t.Fatal("BytesCodec encoded an Int")
+	}
+}
+
+func BenchmarkReadFrom(b *testing.B) {
+	tr := New(*btreeDegree)
+	for _, v := range perm(benchmarkTreeSize) {
+		tr.ReplaceOrInsert(v)
+	}
+	var buf bytes.Buffer
+	if _, err := tr.WriteTo(&buf, IntCodec); err != nil {
+		b.Fatal(err)
+	}
+	data := buf.Bytes()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := ReadFrom(bytes.NewReader(data), IntCodec, *btreeDegree); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,212 @@
+// Copyright 2014 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package btree
+
+// MergeFunc resolves a conflict between equivalent items a and b, taken from
+// the first and second trees of a set operation, by returning the item to
+// keep.  It should return an item equivalent to both.
+type MergeFunc func(a, b Item) Item
+
+// Union returns a new tree holding every item in a or b.  When both hold an
+// equivalent item, resolve picks the one to keep; if resolve is nil, b's item
+// is kept, as if each item of b were added to a with ReplaceOrInsert.
+//
+// Union walks both trees in order, as a merge would, but takes whole
+// subtrees at a time wherever it can: a subtree whose items all fall between
+// two items of the other tree is reused without being descended into, and so
+// is any subtree that a and b still share after Clone, whose items are kept
+// as they are, without calling resolve.  Like Clone, Union leaves the items of
+// a and b unchanged and lazily shares their nodes with the new tree.  Also
+// like Clone, it marks those nodes read-only by giving a and b new
+// copy-on-write contexts, so it counts as a write to both: it must not run
+// concurrently with any other use of a or b.
+//
+// a and b must have the same degree and Monoid, or Union panics.  The new
+// tree uses a's freelist.
+func Union(a, b *BTree, resolve MergeFunc) *BTree {
+	if resolve == nil {
+		resolve = func(_, b Item) Item { return b }
+	}
+	return merge(a, b, setOps{
+		onlyA: true,
+		onlyB: true,
+		both:  resolve,
+	})
+}
+
+// Intersect returns a new tree holding the items that are in both a and b,
+// picking between each pair of equivalent items with resolve; if resolve is
+// nil, a's item is kept.  It works like Union, reusing shared subtrees.
+func Intersect(a, b *BTree, resolve MergeFunc) *BTree {
+	if resolve == nil {
+		resolve = func(a, _ Item) Item { return a }
+	}
+	return merge(a, b, setOps{both: resolve})
+}
+
+// Difference returns a new tree holding the items of a that have no
+// equivalent in b.  It works like Union, skipping any subtree of a which is
+// shared with b.
+func Difference(a, b *BTree) *BTree {
+	return merge(a, b, setOps{onlyA: true})
+}
+
+// UnionWith adds the items of other to t, as by Union.  If other was created
+// by NewMulti, t holds duplicates from then on, as a tree from NewMulti.
+func (t *BTree) UnionWith(other *BTree, resolve MergeFunc) {
+	u := Union(t, other, resolve)
+	t.root, t.length, t.cow, t.multi = u.root, u.length, u.cow, u.multi
+	t.mods++
+}
+
+// setOps says what a set operation keeps.
+type setOps struct {
+	// onlyA and onlyB are true to keep items present in only a or only b.
+	onlyA, onlyB bool
+	// both resolves items present in both trees, or is nil to drop them.
+	both MergeFunc
+}
+
+// merge returns the tree made by walking a and b together, keeping items as
+// ops says.
+func merge(a, b *BTree, ops setOps) *BTree {
+	if a.degree != b.degree {
+		panic("merging trees of different degrees")
+	}
+	if a.cow.monoid != b.cow.monoid {
+		panic("merging trees with different monoids")
+	}
+	out := a.Clone()
+	out.multi = a.multi || b.multi
+	// Mark b's nodes read-only too, the same way Clone does.
+	cow := *b.cow
+	b.cow = &cow
+
+	w := treeWriter{t: out, maxItems: out.maxItems(), minItems: out.minItems()}
+	var ia, ib diffIter
+	ia.init(a.root)
+	ib.init(b.root)
+	for {
+		na, xa, oka := ia.peek()
+		nb, xb, okb := ib.peek()
+		if !oka && !okb {
+			break
+		}
+		if na != nil && na == nb {
+			if ops.onlyA && ops.onlyB || ops.both != nil {
+				w.addNode(na)
+			}
+			ia.next()
+			ib.next()
+			continue
+		}
+		// Find the first item at each side's position, and the last item a
+		// side's subtree would cover.
+		fa, la := xa, xa
+		if na != nil {
+			fa, la = min(na), max(na)
+		}
+		fb, lb := xb, xb
+		if nb != nil {
+			fb, lb = min(nb), max(nb)
+		}
+		switch {
+		case oka && (!okb || la.Less(fb)):
+			// Everything at a's position comes before b's.
+			if ops.onlyA {
+				w.add(na, xa)
+			}
+			ia.next()
+		case okb && (!oka || lb.Less(fa)):
+			if ops.onlyB {
+				w.add(nb, xb)
+			}
+			ib.next()
+		case na != nil && nb != nil:
+			// The subtrees overlap; step into the one starting first.
+			if fb.Less(fa) || (!fa.Less(fb) && nb.size > na.size) {
+				ib.descend()
+			} else {
+				ia.descend()
+			}
+		case na != nil:
+			ia.descend()
+		case nb != nil:
+			ib.descend()
+		default:
+			// Equivalent items.
+			if ops.both != nil {
+				w.addItem(ops.both(xa, xb))
+			}
+			ia.next()
+			ib.next()
+		}
+	}
+	w.finish()
+	return out
+}
+
+// treeWriter assembles a tree from ascending items and subtrees.  Runs of
+// items are bulk loaded with a Builder, and subtrees are joined on whole.
+type treeWriter struct {
+	t                  *BTree
+	maxItems, minItems int
+	root               *node
+	height             int
+	run                *Builder
+}
+
+// add adds the subtree n, or the item if n is nil.
+func (w *treeWriter) add(n *node, item Item) {
+	if n != nil {
+		w.addNode(n)
+	} else {
+		w.addItem(item)
+	}
+}
+
+func (w *treeWriter) addItem(item Item) {
+	if w.run == nil {
+		w.run = newBuilder(&BTree{degree: w.t.degree, cow: w.t.cow, multi: w.t.multi}, DefaultFill)
+	}
+	w.run.push(0, item)
+	w.run.count++
+}
+
+func (w *treeWriter) addNode(n *node) {
+	w.flush()
+	w.root, w.height = w.t.cow.concat(w.root, w.height, n, n.height(), w.minItems, w.maxItems)
+}
+
+// flush joins the current run of items onto the tree.
+func (w *treeWriter) flush() {
+	if w.run == nil {
+		return
+	}
+	run, _ := w.run.Build()
+	w.run = nil
+	if run.root != nil {
+		w.root, w.height = w.t.cow.concat(w.root, w.height, run.root, run.root.height(), w.minItems, w.maxItems)
+	}
+}
+
+// finish stores the assembled tree in w.t.
+func (w *treeWriter) finish() {
+	w.flush()
+	w.t.root, w.t.length = w.root, 0
+	if w.root != nil {
+		w.t.length = w.root.size
+	}
+}
@@ -0,0 +1,186 @@
+// Copyright 2014 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package btree
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// setModel computes the expected result of a set operation on sorted pairs.
+func setModel(a, b []Item, onlyA, onlyB bool, both MergeFunc) (out []Item) {
+	for len(a) > 0 || len(b) > 0 {
+		switch {
+		case len(b) == 0 || (len(a) > 0 && a[0].Less(b[0])):
+			if onlyA {
+				out = append(out, a[0])
+			}
+			a = a[1:]
+		case len(a) == 0 || b[0].Less(a[0]):
+			if onlyB {
+				out = append(out, b[0])
+			}
+			b = b[1:]
+		default:
+			if both != nil {
+				out = append(out, both(a[0], b[0]))
+			}
+			a, b = a[1:], b[1:]
+		}
+	}
+	return out
+}
+
+func checkSetResult(t *testing.T, name string, got *BTree, want []Item) {
+	t.Helper()
+	if items := all(got); !sameItems(items, want) {
+		t.Fatalf("%s:\n got: %v\nwant: %v", name, items, want)
+	}
+	if got.Len() != len(want) {
+		t.Fatalf("%s: Len %d, want %d", name, got.Len(), len(want))
+	}
+	if got.root != nil {
+		checkShape(t, got, got.root, true)
+		checkSizes(t, got.root)
+	}
+}
+
+func sumPairs(a, b Item) Item {
+	return pair{a.(pair).key, a.(pair).val + b.(pair).val}
+}
+
+func TestSetOps(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for _, n := range []int{0, 1, 10, 100, 1000} {
+		for _, degree := range []int{2, 3, 8} {
+			a, b := New(degree), New(degree)
+			for i := 0; i < n; i++ {
+				a.ReplaceOrInsert(pair{r.Intn(2 * n), 1})
+				b.ReplaceOrInsert(pair{r.Intn(2 * n), 2})
+			}
+			// Give b a run of keys entirely after a's, to exercise whole
+			// subtrees being taken from one side.
+			for i := 0; i < n; i++ {
+				b.ReplaceOrInsert(pair{4*n + i, 2})
+			}
+			ia, ib := all(a), all(b)
+			checkSetResult(t, "Union", Union(a, b, sumPairs), setModel(ia, ib, true, true, sumPairs))
+			checkSetResult(t, "Union nil", Union(a, b, nil), setModel(ia, ib, true, true, func(_, b Item) Item { return b }))
+			checkSetResult(t, "Intersect", Intersect(a, b, sumPairs), setModel(ia, ib, false, false, sumPairs))
+			checkSetResult(t, "Intersect nil", Intersect(a, b, nil), setModel(ia, ib, false, false, func(a, _ Item) Item { return a }))
+			checkSetResult(t, "Difference", Difference(a, b), setModel(ia, ib, true, false, nil))
+			checkSetResult(t, "Difference reversed", Difference(b, a), setModel(ib, ia, true, false, nil))
+			if !reflect.DeepEqual(all(a), ia) || !reflect.DeepEqual(all(b), ib) {
+				t.Fatalf("set operation changed its inputs")
+			}
+		}
+	}
+}
+
+func TestSetOpsClones(t *testing.T) {
+	a := New(*btreeDegree)
+	for i := 0; i < 2000; i++ {
+		a.ReplaceOrInsert(pair{i, 0})
+	}
+	b := a.Clone()
+	r := rand.New(rand.NewSource(2))
+	for i := 0; i < 50; i++ {
+		b.Delete(pair{r.Intn(2000), 0})
+		b.ReplaceOrInsert(pair{r.Intn(2500), i + 1})
+		a.ReplaceOrInsert(pair{r.Intn(2500), -i - 1})
+	}
+	ia, ib := all(a), all(b)
+	u := Union(a, b, nil)
+	checkSetResult(t, "Union", u, setModel(ia, ib, true, true, func(_, b Item) Item { return b }))
+	checkSetResult(t, "Intersect", Intersect(a, b, nil), setModel(ia, ib, false, false, func(a, _ Item) Item { return a }))
+	checkSetResult(t, "Difference", Difference(a, b), setModel(ia, ib, true, false, nil))
+
+	// Writes to the result must not leak into its inputs, or back.
+	for i := 0; i < 2500; i += 7 {
+		u.ReplaceOrInsert(pair{i, 1000})
+		a.Delete(pair{i + 1, 0})
+	}
+	if !reflect.DeepEqual(all(b), ib) {
+		t.Fatalf("writes to Union result changed its input")
+	}
+	a.UnionWith(b, nil)
+	checkSetResult(t, "UnionWith", a, setModel(all(a), ib, true, true, func(_, b Item) Item { return b }))
+}
+
+func TestUnionWithMulti(t *testing.T) {
+	m := NewMulti(2)
+	for i := 0; i < 100; i++ {
+		m.InsertNoReplace(pair{i % 10, i})
+	}
+	tr := New(2)
+	tr.UnionWith(m, nil)
+	if err := tr.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if tr.Len() != 100 {
+		t.Fatalf("UnionWith kept %d items, want 100", tr.Len())
+	}
+	// The result goes on accepting duplicates.
+	tr.InsertNoReplace(pair{3, -1})
+	if err := tr.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if tr.Len() != 101 {
+		t.Fatalf("Len %d after inserting a duplicate, want 101", tr.Len())
+	}
+}
+
+func TestUnionSkipsSharedNodes(t *testing.T) {
+	a := New(*btreeDegree)
+	for i := 0; i < 100000; i++ {
+		a.ReplaceOrInsert(countedInt(i))
+	}
+	b := a.Clone()
+	b.ReplaceOrInsert(countedInt(-1))
+	b.Delete(countedInt(50000))
+	countedLess = 0
+	u := Union(a, b, nil)
+	if countedLess > 2000 {
+		t.Errorf("union of clones took %d comparisons", countedLess)
+	}
+	if u.Len() != 100001 || u.Min() != countedInt(-1) || !u.Has(countedInt(50000)) {
+		t.Fatalf("got len %d, min %v", u.Len(), u.Min())
+	}
+	checkShape(t, u, u.root, true)
+	countedLess = 0
+	d := Difference(a, b)
+	if countedLess > 2000 {
+		t.Errorf("difference of clones took %d comparisons", countedLess)
+	}
+	if got := all(d); !reflect.DeepEqual(got, []Item{countedInt(50000)}) {
+		t.Fatalf("Difference: got %v", got)
+	}
+}
+
+func BenchmarkUnion(b *testing.B) {
+	l, r := New(*btreeDegree), New(*btreeDegree)
+	for i, v := range perm(benchmarkTreeSize) {
+		if i%2 == 0 {
+			l.ReplaceOrInsert(v)
+		} else {
+			r.ReplaceOrInsert(v)
+		}
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Union(l, r, nil)
+	}
+}
@@ -0,0 +1,80 @@
+// Copyright 2014 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package btree
+
+// TreeStats describes the shape of a tree, as returned by BTree.Stats.
+type TreeStats struct {
+	// Items is the number of items in the tree, as returned by Len.
+	Items int
+	// Height is the number of levels in the tree, or 0 if it is empty.
+	Height int
+	// InternalNodes and LeafNodes count the nodes with and without children.
+	InternalNodes, LeafNodes int
+	// AvgFill is the mean number of items per node, as a fraction of the most
+	// a node can hold.
+	AvgFill float64
+	// MinFill is the fill of the emptiest node, not counting the root, which
+	// may hold as few as one item.  It is the root's fill if the root is the
+	// only node.
+	MinFill float64
+	// OwnedNodes counts the nodes the tree may write to in place.  The rest,
+	// SharedNodes, are shared with clones made by Clone (or with the tree
+	// they were cloned from), and are copied on the tree's next write to
+	// them.
+	OwnedNodes, SharedNodes int
+	// FreeListNodes is the number of nodes in the tree's FreeList, which holds
+	// at most FreeListSize.  The FreeList may be shared with other trees.
+	FreeListNodes, FreeListSize int
+}
+
+// Stats returns statistics about the structure of t.  It visits every node,
+// so takes O(n) time.
+func (t *BTree) Stats() TreeStats {
+	s := TreeStats{Items: t.length}
+	s.FreeListNodes, s.FreeListSize = t.cow.freelist.stats()
+	if t.root == nil {
+		return s
+	}
+	s.Height = t.root.height()
+	maxItems := float64(t.maxItems())
+	s.MinFill = float64(len(t.root.items)) / maxItems
+	total, minItems := 0, -1
+	var walk func(n *node, isRoot bool)
+	walk = func(n *node, isRoot bool) {
+		if len(n.children) > 0 {
+			s.InternalNodes++
+		} else {
+			s.LeafNodes++
+		}
+		if n.cow == t.cow {
+			s.OwnedNodes++
+		} else {
+			s.SharedNodes++
+		}
+		total += len(n.items)
+		if !isRoot && (minItems < 0 || len(n.items) < minItems) {
+			minItems = len(n.items)
+		}
+		for _, c := range n.children {
+			walk(c, false)
+		}
+	}
+	walk(t.root, true)
+	s.AvgFill = float64(total) / float64(s.InternalNodes+s.LeafNodes) / maxItems
+	if minItems >= 0 {
+		s.MinFill = float64(minItems) / maxItems
+	}
+	return s
+}
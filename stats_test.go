@@ -0,0 +1,64 @@
+// Copyright 2014 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package btree
+
+import (
+	"testing"
+)
+
+func TestStats(t *testing.T) {
+	tr := New(2)
+	if got, want := tr.Stats(), (TreeStats{FreeListSize: DefaultFreeListSize}); got != want {
+		t.Fatalf("empty tree: got %+v, want %+v", got, want)
+	}
+	for i := 0; i < 7; i++ {
+		tr.ReplaceOrInsert(Int(i))
+	}
+	// Inserting 0..6 in order into a 2-3-4 tree leaves it as
+	//   [1 3]
+	//   [0] [2] [4 5 6]
+	want := TreeStats{
+		Items:         7,
+		Height:        2,
+		InternalNodes: 1,
+		LeafNodes:     3,
+		AvgFill:       7.0 / 4 / 3,
+		MinFill:       1.0 / 3,
+		OwnedNodes:    4,
+		FreeListSize:  DefaultFreeListSize,
+	}
+	if got := tr.Stats(); got != want {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+
+	clone := tr.Clone()
+	clone.ReplaceOrInsert(Int(7))
+	if s := tr.Stats(); s.OwnedNodes != 0 || s.SharedNodes != 4 {
+		t.Errorf("after Clone, original has %d owned and %d shared nodes", s.OwnedNodes, s.SharedNodes)
+	}
+	// The insert copied the root and the rightmost leaf, then split the leaf.
+	if s := clone.Stats(); s.OwnedNodes != 3 || s.SharedNodes != 2 {
+		t.Errorf("after insert, clone has %d owned and %d shared nodes", s.OwnedNodes, s.SharedNodes)
+	}
+
+	tr.Clear(true)
+	if s := tr.Stats(); s.FreeListNodes != 0 {
+		t.Errorf("clearing shared nodes freed %d of them", s.FreeListNodes)
+	}
+	clone.Clear(true)
+	if s := clone.Stats(); s.FreeListNodes != 3 {
+		t.Errorf("clearing clone freed %d nodes, want 3", s.FreeListNodes)
+	}
+}
@@ -0,0 +1,283 @@
+// Copyright 2014 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package btree
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// SyncBTree is a BTree that is safe for concurrent use by multiple goroutines.
+//
+// Writers are serialized by a mutex.  Readers never take that mutex: after
+// each write, a Clone of the tree is published as the current Snapshot, and
+// reads are served from whichever snapshot was current when they started.  A
+// long scan therefore never blocks inserts, and sees none of the writes made
+// while it runs.
+//
+// Since every write publishes a clone, the following write pays the
+// copy-on-write cost of Clone, copying the O(log n) nodes on its path.  Use
+// Update to apply several writes under a single publish.
+type SyncBTree struct {
+	mu   sync.Mutex // serializes writers
+	t    *BTree     // the writers' tree, only used with mu held
+	snap atomic.Pointer[Snapshot]
+}
+
+// Snapshot is a read-only view of a SyncBTree at some point in time.  It is
+// safe for concurrent use, and is unaffected by later writes to the
+// SyncBTree it came from.
+type Snapshot struct {
+	t *BTree
+}
+
+// NewSync creates a new, empty SyncBTree with the given degree.
+func NewSync(degree int) *SyncBTree {
+	return NewSyncFrom(New(degree))
+}
+
+// NewSyncFrom creates a new SyncBTree holding the items of t, which should not
+// be used directly afterwards.
+func NewSyncFrom(t *BTree) *SyncBTree {
+	s := &SyncBTree{t: t}
+	s.publish()
+	return s
+}
+
+// publish makes the current state of s.t visible to readers.  It must be
+// called with s.mu held, or before s is shared.
+func (s *SyncBTree) publish() {
+	s.snap.Store(&Snapshot{t: s.t.Clone()})
+}
+
+// Snapshot returns the current read-only view of the tree.  It never blocks.
+func (s *SyncBTree) Snapshot() *Snapshot {
+	return s.snap.Load()
+}
+
+// Update calls fn with the underlying tree while holding the write lock, and
+// publishes the result as a single new snapshot, so readers see all of fn's
+// writes or none of them.  fn must not keep t or use it after returning.
+func (s *SyncBTree) Update(fn func(t *BTree)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fn(s.t)
+	s.publish()
+}
+
+// ReplaceOrInsert is like BTree.ReplaceOrInsert.
+func (s *SyncBTree) ReplaceOrInsert(item Item) (out Item) {
+	s.Update(func(t *BTree) { out = t.ReplaceOrInsert(item) })
+	return
+}
+
+// InsertNoReplace is like BTree.InsertNoReplace.
+func (s *SyncBTree) InsertNoReplace(item Item) {
+	s.Update(func(t *BTree) { t.InsertNoReplace(item) })
+}
+
+// Delete is like BTree.Delete.
+func (s *SyncBTree) Delete(item Item) (out Item) {
+	s.Update(func(t *BTree) { out = t.Delete(item) })
+	return
+}
+
+// DeleteMin is like BTree.DeleteMin.
+func (s *SyncBTree) DeleteMin() (out Item) {
+	s.Update(func(t *BTree) { out = t.DeleteMin() })
+	return
+}
+
+// DeleteMax is like BTree.DeleteMax.
+func (s *SyncBTree) DeleteMax() (out Item) {
+	s.Update(func(t *BTree) { out = t.DeleteMax() })
+	return
+}
+
+// DeleteRange is like BTree.DeleteRange.
+func (s *SyncBTree) DeleteRange(greaterOrEqual, lessThan Item) (out int) {
+	s.Update(func(t *BTree) { out = t.DeleteRange(greaterOrEqual, lessThan) })
+	return
+}
+
+// Clear is like BTree.Clear.
+func (s *SyncBTree) Clear(addNodesToFreelist bool) {
+	s.Update(func(t *BTree) { t.Clear(addNodesToFreelist) })
+}
+
+// AscendRange is like BTree.AscendRange, over the current snapshot.
+func (s *SyncBTree) AscendRange(greaterOrEqual, lessThan Item, iterator ItemIterator) {
+	s.Snapshot().AscendRange(greaterOrEqual, lessThan, iterator)
+}
+
+// AscendLessThan is like BTree.AscendLessThan, over the current snapshot.
+func (s *SyncBTree) AscendLessThan(pivot Item, iterator ItemIterator) {
+	s.Snapshot().AscendLessThan(pivot, iterator)
+}
+
+// AscendGreaterOrEqual is like BTree.AscendGreaterOrEqual, over the current
+// snapshot.
+func (s *SyncBTree) AscendGreaterOrEqual(pivot Item, iterator ItemIterator) {
+	s.Snapshot().AscendGreaterOrEqual(pivot, iterator)
+}
+
+// Ascend is like BTree.Ascend, over the current snapshot.
+func (s *SyncBTree) Ascend(iterator ItemIterator) {
+	s.Snapshot().Ascend(iterator)
+}
+
+// DescendRange is like BTree.DescendRange, over the current snapshot.
+func (s *SyncBTree) DescendRange(lessOrEqual, greaterThan Item, iterator ItemIterator) {
+	s.Snapshot().DescendRange(lessOrEqual, greaterThan, iterator)
+}
+
+// DescendLessOrEqual is like BTree.DescendLessOrEqual, over the current
+// snapshot.
+func (s *SyncBTree) DescendLessOrEqual(pivot Item, iterator ItemIterator) {
+	s.Snapshot().DescendLessOrEqual(pivot, iterator)
+}
+
+// DescendGreaterThan is like BTree.DescendGreaterThan, over the current
+// snapshot.
+func (s *SyncBTree) DescendGreaterThan(pivot Item, iterator ItemIterator) {
+	s.Snapshot().DescendGreaterThan(pivot, iterator)
+}
+
+// Descend is like BTree.Descend, over the current snapshot.
+func (s *SyncBTree) Descend(iterator ItemIterator) {
+	s.Snapshot().Descend(iterator)
+}
+
+// Get is like BTree.Get, over the current snapshot.
+func (s *SyncBTree) Get(key Item) Item {
+	return s.Snapshot().Get(key)
+}
+
+// Has is like BTree.Has, over the current snapshot.
+func (s *SyncBTree) Has(key Item) bool {
+	return s.Snapshot().Has(key)
+}
+
+// Min is like BTree.Min, over the current snapshot.
+func (s *SyncBTree) Min() Item {
+	return s.Snapshot().Min()
+}
+
+// Max is like BTree.Max, over the current snapshot.
+func (s *SyncBTree) Max() Item {
+	return s.Snapshot().Max()
+}
+
+// Len is like BTree.Len, over the current snapshot.
+func (s *SyncBTree) Len() int {
+	return s.Snapshot().Len()
+}
+
+// AscendRange is like BTree.AscendRange.
+func (s *Snapshot) AscendRange(greaterOrEqual, lessThan Item, iterator ItemIterator) {
+	s.t.AscendRange(greaterOrEqual, lessThan, iterator)
+}
+
+// AscendLessThan is like BTree.AscendLessThan.
+func (s *Snapshot) AscendLessThan(pivot Item, iterator ItemIterator) {
+	s.t.AscendLessThan(pivot, iterator)
+}
+
+// AscendGreaterOrEqual is like BTree.AscendGreaterOrEqual.
+func (s *Snapshot) AscendGreaterOrEqual(pivot Item, iterator ItemIterator) {
+	s.t.AscendGreaterOrEqual(pivot, iterator)
+}
+
+// Ascend is like BTree.Ascend.
+func (s *Snapshot) Ascend(iterator ItemIterator) {
+	s.t.Ascend(iterator)
+}
+
+// DescendRange is like BTree.DescendRange.
+func (s *Snapshot) DescendRange(lessOrEqual, greaterThan Item, iterator ItemIterator) {
+	s.t.DescendRange(lessOrEqual, greaterThan, iterator)
+}
+
+// DescendLessOrEqual is like BTree.DescendLessOrEqual.
+func (s *Snapshot) DescendLessOrEqual(pivot Item, iterator ItemIterator) {
+	s.t.DescendLessOrEqual(pivot, iterator)
+}
+
+// DescendGreaterThan is like BTree.DescendGreaterThan.
+func (s *Snapshot) DescendGreaterThan(pivot Item, iterator ItemIterator) {
+	s.t.DescendGreaterThan(pivot, iterator)
+}
+
+// Descend is like BTree.Descend.
+func (s *Snapshot) Descend(iterator ItemIterator) {
+	s.t.Descend(iterator)
+}
+
+// Get is like BTree.Get.
+func (s *Snapshot) Get(key Item) Item {
+	return s.t.Get(key)
+}
+
+// GetAt is like BTree.GetAt.
+func (s *Snapshot) GetAt(index int) Item {
+	return s.t.GetAt(index)
+}
+
+// Rank is like BTree.Rank.
+func (s *Snapshot) Rank(key Item) (int, bool) {
+	return s.t.Rank(key)
+}
+
+// Count is like BTree.Count.
+func (s *Snapshot) Count(key Item) int {
+	return s.t.Count(key)
+}
+
+// Has is like BTree.Has.
+func (s *Snapshot) Has(key Item) bool {
+	return s.t.Has(key)
+}
+
+// Min is like BTree.Min.
+func (s *Snapshot) Min() Item {
+	return s.t.Min()
+}
+
+// Max is like BTree.Max.
+func (s *Snapshot) Max() Item {
+	return s.t.Max()
+}
+
+// Len is like BTree.Len.
+func (s *Snapshot) Len() int {
+	return s.t.Len()
+}
+
+// Cursor returns a new, unpositioned Cursor over the snapshot.  The cursor
+// stays valid regardless of writes to the SyncBTree.
+func (s *Snapshot) Cursor() *Cursor {
+	return s.t.Cursor()
+}
+
+// Clone returns a new, writable BTree holding the snapshot's items.  It
+// shares nodes with the snapshot lazily, like BTree.Clone.
+func (s *Snapshot) Clone() *BTree {
+	// s.t is never written, so copying it with a fresh copy-on-write context
+	// leaves it untouched and is safe to do concurrently.
+	cow := *s.t.cow
+	out := *s.t
+	out.cow = &cow
+	return &out
+}
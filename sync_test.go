@@ -0,0 +1,109 @@
+// Copyright 2014 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package btree
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestSyncBTree(t *testing.T) {
+	s := NewSync(*btreeDegree)
+	for _, v := range perm(1000) {
+		if x := s.ReplaceOrInsert(v); x != nil {
+			t.Fatal("insert found item", v)
+		}
+	}
+	if s.Len() != 1000 || s.Min() != Int(0) || s.Max() != Int(999) || !s.Has(Int(500)) {
+		t.Fatalf("got len %d, min %v, max %v", s.Len(), s.Min(), s.Max())
+	}
+	snap := s.Snapshot()
+	if got := s.Delete(Int(500)); got != Int(500) {
+		t.Fatalf("Delete: got %v", got)
+	}
+	if s.Has(Int(500)) || !snap.Has(Int(500)) || snap.Len() != 1000 {
+		t.Fatalf("snapshot changed by a later write")
+	}
+	s.Update(func(tr *BTree) {
+		tr.DeleteRange(Int(0), Int(100))
+		tr.DeleteMin()
+	})
+	if s.Min() != Int(101) || s.Len() != 898 {
+		t.Fatalf("after Update: min %v, len %d", s.Min(), s.Len())
+	}
+	var got []Item
+	snap.AscendRange(Int(0), Int(10), func(a Item) bool {
+		got = append(got, a)
+		return true
+	})
+	if want := rang(10); !reflect.DeepEqual(got, want) {
+		t.Fatalf("snapshot scan:\n got: %v\nwant: %v", got, want)
+	}
+	c := snap.Clone()
+	c.Clear(false)
+	if snap.Len() != 1000 || len(all(snap.t)) != 1000 {
+		t.Fatalf("snapshot changed by writes to its clone")
+	}
+}
+
+func TestSyncBTreeConcurrent(t *testing.T) {
+	s := NewSync(4)
+	const writers, perWriter = 4, 1000
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < perWriter; i++ {
+				s.ReplaceOrInsert(Int(w*perWriter + i))
+			}
+		}(w)
+	}
+	var readers sync.WaitGroup
+	for r := 0; r < 4; r++ {
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				snap := s.Snapshot()
+				n, prev := 0, Item(nil)
+				snap.Ascend(func(a Item) bool {
+					if prev != nil && !prev.Less(a) {
+						t.Errorf("snapshot out of order: %v then %v", prev, a)
+					}
+					prev = a
+					n++
+					return true
+				})
+				if n != snap.Len() {
+					t.Errorf("snapshot holds %d items, but Len is %d", n, snap.Len())
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(done)
+	readers.Wait()
+	if got := all(s.Snapshot().t); !reflect.DeepEqual(got, rang(writers*perWriter)) {
+		t.Fatalf("got %d items, want %d", len(got), writers*perWriter)
+	}
+}
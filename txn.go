@@ -0,0 +1,102 @@
+// Copyright 2014 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package btree
+
+import "errors"
+
+// ErrConflict is returned by Txn.Commit when the transaction's tree was
+// written to after the transaction began.
+var ErrConflict = errors.New("btree: tree modified during transaction")
+
+// Txn is a group of reads and writes to a BTree that is applied all at once,
+// or not at all.
+//
+// A Txn offers the whole BTree API, through its embedded BTree: a private
+// view of the tree which starts out as a Clone of it, so the transaction
+// reads its own writes, while the tree itself is unchanged until Commit.
+// Neither the Txn nor its view may be used after Commit or Rollback.
+type Txn struct {
+	*BTree
+	parent *BTree
+	// base is parent's count of writes when the transaction began.
+	base uint64
+	// cow is parent's copy-on-write context before Begin, and parentCow and
+	// viewCow are the contexts Begin gave parent and the view in its place.
+	cow, parentCow, viewCow *copyOnWriteContext
+}
+
+// Begin starts a transaction on t.
+//
+// Like Clone, Begin is O(1), and the transaction's writes copy only the nodes
+// they touch.  Those copies are the only cost of a transaction: Commit makes
+// them t's own nodes, and Rollback returns them to t's freelist.
+//
+// Also like Clone, Begin marks t's nodes read-only, so t's own writes copy
+// the nodes they touch until the transaction finishes.  Rollback gives t its
+// nodes back, unless t was changed or cloned while the transaction ran, or the
+// transaction's view was cloned; Commit leaves t owning only the nodes the
+// transaction wrote.
+func (t *BTree) Begin() *Txn {
+	cow := t.cow
+	view := t.Clone()
+	return &Txn{BTree: view, parent: t, base: t.mods, cow: cow, parentCow: t.cow, viewCow: view.cow}
+}
+
+// Commit applies the transaction's writes to its tree, by making the
+// transaction's view the tree's contents.  If the tree was changed after the
+// transaction began, by a write or by another transaction's Commit, Commit
+// instead rolls the transaction back, and returns ErrConflict.  Writes that
+// find nothing to change, such as deleting a missing item, don't count.
+//
+// Commit is a write to the tree: it replaces the tree's root, length and
+// copy-on-write context one field at a time, so like any other write it must
+// not run concurrently with other uses of the tree.  Use a SyncBTree to share
+// a tree with concurrent readers.
+func (txn *Txn) Commit() error {
+	if txn.parent == nil {
+		panic("btree: transaction already finished")
+	}
+	t := txn.parent
+	if t.mods != txn.base {
+		txn.Rollback()
+		return ErrConflict
+	}
+	view := txn.BTree
+	t.root, t.length, t.cow = view.root, view.length, view.cow
+	t.mods++
+	txn.BTree, txn.parent, txn.base = nil, nil, 0
+	txn.cow, txn.parentCow, txn.viewCow = nil, nil, nil
+	return nil
+}
+
+// Rollback discards the transaction's writes, returning the nodes they
+// created to the tree's freelist, and gives the tree back ownership of its
+// nodes, as described at Begin.  Calling Rollback after the transaction
+// has finished does nothing, so it may be deferred to clean up after a
+// transaction that might not reach Commit.
+func (txn *Txn) Rollback() {
+	if txn.parent == nil {
+		return
+	}
+	t := txn.parent
+	txn.BTree.Clear(true)
+	if t.mods == txn.base && t.cow == txn.parentCow && txn.BTree.cow == txn.viewCow {
+		// No other tree has been given t's nodes since Begin, so t can own
+		// them again.
+		t.cow = txn.cow
+	}
+	txn.BTree, txn.parent, txn.base = nil, nil, 0
+	txn.cow, txn.parentCow, txn.viewCow = nil, nil, nil
+}
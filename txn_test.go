@@ -0,0 +1,153 @@
+// Copyright 2014 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package btree
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTxnCommit(t *testing.T) {
+	tr := New(*btreeDegree)
+	for _, v := range perm(100) {
+		tr.ReplaceOrInsert(v)
+	}
+	txn := tr.Begin()
+	defer txn.Rollback()
+	for i := 100; i < 200; i++ {
+		txn.ReplaceOrInsert(Int(i))
+	}
+	txn.DeleteRange(Int(0), Int(50))
+	if txn.Len() != 150 || txn.Min() != Int(50) || !txn.Has(Int(150)) {
+		t.Fatalf("transaction doesn't see its own writes: len %d, min %v", txn.Len(), txn.Min())
+	}
+	if tr.Len() != 100 || !reflect.DeepEqual(all(tr), rang(100)) {
+		t.Fatalf("tree changed before Commit")
+	}
+	if err := txn.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if want := rang(200)[50:]; !reflect.DeepEqual(all(tr), want) {
+		t.Fatalf("after Commit:\n got: %v\nwant: %v", all(tr), want)
+	}
+	if err := tr.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	// The tree owns the transaction's nodes after Commit.
+	if s := tr.Stats(); s.OwnedNodes == 0 {
+		t.Errorf("committed tree owns no nodes")
+	}
+	tr.ReplaceOrInsert(Int(1000))
+	if tr.Len() != 151 {
+		t.Fatalf("write after Commit: len %d", tr.Len())
+	}
+}
+
+func TestTxnRollback(t *testing.T) {
+	tr := NewWithFreeList(*btreeDegree, NewFreeList(1000))
+	for _, v := range perm(1000) {
+		tr.ReplaceOrInsert(v)
+	}
+	before := tr.Stats().FreeListNodes
+	txn := tr.Begin()
+	for i := 0; i < 1000; i += 100 {
+		txn.Delete(Int(i))
+	}
+	owned := txn.Stats().OwnedNodes
+	txn.Rollback()
+	if !reflect.DeepEqual(all(tr), rang(1000)) {
+		t.Fatalf("tree changed by rolled back transaction")
+	}
+	if got, want := tr.Stats().FreeListNodes, before+owned; got != want {
+		t.Errorf("Rollback freed %d nodes, want %d", got-before, owned)
+	}
+	txn.Rollback()
+}
+
+func TestTxnRollbackOwnership(t *testing.T) {
+	tr := New(*btreeDegree)
+	for _, v := range perm(1000) {
+		tr.ReplaceOrInsert(v)
+	}
+	owned := tr.Stats().OwnedNodes
+	txn := tr.Begin()
+	txn.Delete(Int(5))
+	txn.Rollback()
+	if got := tr.Stats().OwnedNodes; got != owned {
+		t.Fatalf("after Rollback, tree owns %d nodes, want %d", got, owned)
+	}
+
+	// A clone of the view still shares the tree's nodes, so the tree mustn't
+	// take them back.
+	txn = tr.Begin()
+	clone := txn.Clone()
+	txn.Rollback()
+	if got := tr.Stats().OwnedNodes; got != 0 {
+		t.Fatalf("after Rollback of a cloned view, tree owns %d nodes", got)
+	}
+	for i := 0; i < 1000; i++ {
+		tr.Delete(Int(i))
+	}
+	if !reflect.DeepEqual(all(clone), rang(1000)) {
+		t.Fatalf("writes to the tree changed a clone of a rolled back view")
+	}
+}
+
+func TestTxnConflict(t *testing.T) {
+	tr := New(*btreeDegree)
+	for _, v := range perm(100) {
+		tr.ReplaceOrInsert(v)
+	}
+	txn := tr.Begin()
+	txn.Delete(Int(5))
+	tr.Delete(Int(6))
+	if err := txn.Commit(); err != ErrConflict {
+		t.Fatalf("Commit after a write to the tree: got %v, want ErrConflict", err)
+	}
+	if !tr.Has(Int(5)) || tr.Has(Int(6)) {
+		t.Fatalf("conflicting Commit changed the tree")
+	}
+
+	// Writes to the tree that change nothing don't conflict.
+	txn = tr.Begin()
+	txn.Delete(Int(5))
+	tr.Delete(Int(6))
+	tr.DeleteRange(Int(200), Int(300))
+	tr.InsertNoReplace(Int(7))
+	tr.Upsert(Int(8), func(Item, bool) (Item, UpsertAction) { return nil, UpsertKeep })
+	if err := txn.Commit(); err != nil {
+		t.Fatalf("Commit after no-op writes to the tree: %v", err)
+	}
+	if tr.Has(Int(5)) {
+		t.Fatalf("Commit didn't apply the transaction")
+	}
+
+	// A transaction that commits while another runs conflicts with it.
+	txn, other := tr.Begin(), tr.Begin()
+	txn.Delete(Int(9))
+	other.Delete(Int(10))
+	if err := other.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if err := txn.Commit(); err != ErrConflict {
+		t.Fatalf("Commit after another Commit: got %v, want ErrConflict", err)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Errorf("second Commit didn't panic")
+		}
+	}()
+	txn.Commit()
+}
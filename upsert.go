@@ -0,0 +1,168 @@
+// Copyright 2014 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package btree
+
+// UpsertAction says what Upsert should do with the item its UpsertFunc
+// returns.
+type UpsertAction int
+
+const (
+	// UpsertKeep leaves the tree as it is.
+	UpsertKeep UpsertAction = iota
+	// UpsertSet stores the returned item, replacing the old one, or inserting
+	// it if there was none.
+	UpsertSet
+	// UpsertDelete removes the old item, if there was one.
+	UpsertDelete
+)
+
+// UpsertFunc is called by Upsert with the item in the tree equivalent to its
+// key, or nil and false if there is none, and returns an item and what to do
+// with it.  The returned item is ignored unless the action is UpsertSet, in
+// which case it must be non-nil and equivalent to the key.
+type UpsertFunc func(old Item, exists bool) (Item, UpsertAction)
+
+// Upsert looks up key and calls fn with the item equivalent to it, if any,
+// then keeps, replaces, inserts or deletes the item as fn says, all in one
+// descent from the root.  It is equivalent to, but cheaper than, a Get
+// followed by ReplaceOrInsert or Delete.  In a tree created by NewMulti, fn is
+// given the first of the items equivalent to key.
+//
+// Like ReplaceOrInsert, Upsert splits full nodes on its way down, so there is
+// room to insert at the bottom, and copies the nodes it passes through that t
+// shares with a clone; it does both even when fn returns UpsertKeep.  Nodes
+// left with too few items by a deletion are refilled on the way back up.
+//
+// fn is called while t is part way through being modified, so it must not
+// use t.  Upsert panics if fn returns UpsertSet with an item that is nil or
+// not equivalent to key.
+func (t *BTree) Upsert(key Item, fn UpsertFunc) {
+	if key == nil {
+		panic("nil key passed to Upsert")
+	}
+	if t.root == nil {
+		if item, action := fn(nil, false); action == UpsertSet {
+			checkUpsert(key, item)
+			t.insert(item, false)
+		}
+		return
+	}
+	t.splitRoot()
+	delta, changed := t.root.upsert(key, fn, t.minItems(), t.maxItems(), t.multi)
+	t.length += delta
+	if changed {
+		t.mods++
+	}
+	if len(t.root.items) == 0 && len(t.root.children) > 0 {
+		oldroot := t.root
+		t.root = t.root.children[0]
+		t.cow.freeNode(oldroot)
+	}
+}
+
+// checkUpsert panics unless item, returned by an UpsertFunc for key with
+// UpsertSet, can be stored in key's place.
+func checkUpsert(key, item Item) {
+	if item == nil {
+		panic("nil item being added to BTree")
+	}
+	if key.Less(item) || item.Less(key) {
+		panic("Upsert item is not equivalent to its key")
+	}
+}
+
+// findFirst returns the index of the first item in n equivalent to key, and
+// true, or else the index of the child to descend into to find key, and
+// false.  In a multiset tree, where equivalent items may span several nodes,
+// an item of n equivalent to key is only the first if the child before it
+// holds none.
+func (n *node) findFirst(key Item, multi bool) (int, bool) {
+	if !multi {
+		return n.items.find(key)
+	}
+	i := n.items.lowerBound(key)
+	if i == len(n.items) || key.Less(n.items[i]) {
+		return i, false
+	}
+	if len(n.children) > 0 && !max(n.children[i]).Less(key) {
+		return i, false
+	}
+	return i, true
+}
+
+// upsert performs Upsert on the subtree rooted at this node, which must have
+// room for another item, and returns the change in the number of items it
+// holds, -1, 0 or 1, and whether any item was stored or deleted.  Like insert, it splits a full child before descending
+// into it.  When an item is deleted, the child it was deleted from may be left
+// with fewer than minItems items, and is grown afterwards.
+func (n *node) upsert(key Item, fn UpsertFunc, minItems, maxItems int, multi bool) (int, bool) {
+	i, found := n.findFirst(key, multi)
+	if found {
+		return n.upsertAt(i, key, fn, minItems)
+	}
+	if len(n.children) == 0 {
+		item, action := fn(nil, false)
+		if action != UpsertSet {
+			return 0, false
+		}
+		checkUpsert(key, item)
+		n.items.insertAt(i, item)
+		n.size++
+		n.reaggregate()
+		return 1, true
+	}
+	if n.maybeSplitChild(i, maxItems) {
+		// The split moved an item up into n, which may be the one we want, and
+		// which decides the half to descend into.
+		if i, found = n.findFirst(key, multi); found {
+			return n.upsertAt(i, key, fn, minItems)
+		}
+	}
+	child := n.mutableChild(i)
+	delta, changed := child.upsert(key, fn, minItems, maxItems, multi)
+	n.size += delta
+	if len(child.items) < minItems {
+		n.growChild(i, minItems)
+	}
+	n.reaggregate()
+	return delta, changed
+}
+
+// upsertAt performs Upsert on n.items[i], the item found for key.
+func (n *node) upsertAt(i int, key Item, fn UpsertFunc, minItems int) (int, bool) {
+	item, action := fn(n.items[i], true)
+	switch action {
+	case UpsertSet:
+		checkUpsert(key, item)
+		n.items[i] = item
+		n.reaggregate()
+		return 0, true
+	case UpsertDelete:
+		n.size--
+		if len(n.children) == 0 {
+			n.items.removeAt(i)
+		} else {
+			// As in remove, replace the item with its predecessor.
+			child := n.mutableChild(i)
+			n.items[i] = child.remove(nil, minItems, removeMax)
+			if len(child.items) < minItems {
+				n.growChild(i, minItems)
+			}
+		}
+		n.reaggregate()
+		return -1, true
+	}
+	return 0, false
+}
@@ -0,0 +1,173 @@
+// Copyright 2014 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package btree
+
+import (
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestUpsert(t *testing.T) {
+	for _, degree := range []int{2, 3, 4, *btreeDegree} {
+		tr := New(degree)
+		tr.SetMonoid(sumVals)
+		model := map[int]int{}
+		r := rand.New(rand.NewSource(int64(degree)))
+		for i := 0; i < 20000; i++ {
+			k := r.Intn(500)
+			action := UpsertAction(r.Intn(3))
+			if r.Intn(4) == 0 {
+				// Favour deletes, so that nodes underflow often.
+				action = UpsertDelete
+			}
+			v, exists := model[k]
+			tr.Upsert(pair{k, 0}, func(old Item, ok bool) (Item, UpsertAction) {
+				if ok != exists || (ok && old.(pair).val != v) {
+					t.Fatalf("degree %d: key %d: fn got %v, %v, want val %d, %v", degree, k, old, ok, v, exists)
+				}
+				return pair{k, v + 1}, action
+			})
+			switch action {
+			case UpsertSet:
+				model[k] = v + 1
+			case UpsertDelete:
+				delete(model, k)
+			}
+			if i%500 == 0 {
+				if err := tr.Validate(); err != nil {
+					t.Fatalf("degree %d: after %d upserts: %v", degree, i, err)
+				}
+				if tr.root != nil {
+					checkAggs(t, tr.root, sumVals)
+				}
+			}
+		}
+		if err := tr.Validate(); err != nil {
+			t.Fatal(err)
+		}
+		var want []Item
+		for k, v := range model {
+			want = append(want, pair{k, v})
+		}
+		sort.Slice(want, func(i, j int) bool { return want[i].Less(want[j]) })
+		if got := all(tr); !sameItems(got, want) {
+			t.Fatalf("degree %d: got %d items, want %d", degree, len(got), len(want))
+		}
+		// Empty the tree entirely through Upsert.
+		for k := range model {
+			tr.Upsert(pair{k, 0}, func(Item, bool) (Item, UpsertAction) { return nil, UpsertDelete })
+		}
+		if tr.Len() != 0 || len(all(tr)) != 0 {
+			t.Fatalf("degree %d: tree not empty after deleting everything", degree)
+		}
+		if err := tr.Validate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestUpsertMulti(t *testing.T) {
+	tr := NewMulti(2)
+	for i := 0; i < 300; i++ {
+		tr.InsertNoReplace(pair{i % 10, i})
+	}
+	for i := 0; i < 10; i++ {
+		var got Item
+		tr.Upsert(pair{i, 0}, func(old Item, _ bool) (Item, UpsertAction) {
+			got = old
+			return nil, UpsertDelete
+		})
+		// The first of the equivalent items is the first inserted.
+		if want := (pair{i, i}); got != want {
+			t.Fatalf("Upsert(%d) found %v, want %v", i, got, want)
+		}
+		if err := tr.Validate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if tr.Len() != 290 {
+		t.Fatalf("Len %d, want 290", tr.Len())
+	}
+	tr.Upsert(pair{3, 0}, func(Item, bool) (Item, UpsertAction) { return pair{3, -1}, UpsertSet })
+	if got := tr.Get(pair{3, 0}); got != (pair{3, -1}) {
+		t.Fatalf("Upsert replaced the wrong item: first is %v", got)
+	}
+}
+
+func TestUpsertClone(t *testing.T) {
+	tr := New(*btreeDegree)
+	for i := 0; i < 1000; i++ {
+		tr.ReplaceOrInsert(pair{i, 0})
+	}
+	want := all(tr)
+	clone := tr.Clone()
+	for i := 0; i < 1000; i += 3 {
+		tr.Upsert(pair{i, 0}, func(Item, bool) (Item, UpsertAction) { return nil, UpsertDelete })
+		tr.Upsert(pair{i + 1, 0}, func(Item, bool) (Item, UpsertAction) { return pair{i + 1, 1}, UpsertSet })
+	}
+	if got := all(clone); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Upsert changed a clone")
+	}
+}
+
+func TestUpsertPanics(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		item Item
+	}{
+		{"nil item", nil},
+		{"different key", pair{2, 0}},
+	} {
+		for _, size := range []int{0, 100} {
+			func() {
+				defer func() {
+					if recover() == nil {
+						t.Errorf("%s, size %d: Upsert did not panic", test.name, size)
+					}
+				}()
+				tr := New(2)
+				for i := 0; i < size; i++ {
+					tr.ReplaceOrInsert(pair{i, 0})
+				}
+				tr.Upsert(pair{1, 0}, func(Item, bool) (Item, UpsertAction) { return test.item, UpsertSet })
+			}()
+		}
+	}
+}
+
+func BenchmarkUpsert(b *testing.B) {
+	tr := New(*btreeDegree)
+	for i := 0; i < benchmarkTreeSize; i++ {
+		tr.ReplaceOrInsert(pair{i, 0})
+	}
+	keys := rand.Perm(benchmarkTreeSize)
+	b.Run("Upsert", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			k := keys[i%benchmarkTreeSize]
+			tr.Upsert(pair{k, 0}, func(old Item, _ bool) (Item, UpsertAction) {
+				return pair{k, old.(pair).val + 1}, UpsertSet
+			})
+		}
+	})
+	b.Run("GetReplaceOrInsert", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			k := keys[i%benchmarkTreeSize]
+			old := tr.Get(pair{k, 0})
+			tr.ReplaceOrInsert(pair{k, old.(pair).val + 1})
+		}
+	})
+}
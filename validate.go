@@ -0,0 +1,137 @@
+// Copyright 2014 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package btree
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Validate checks that t is a well-formed B-Tree, returning an error
+// describing the first problem it finds, or nil.  It checks that:
+//
+//   - every internal node has one more child than it has items;
+//   - every node but the root holds between minItems and maxItems items;
+//   - items are in ascending order, both within each node and across
+//     subtrees, with no equivalent items unless t was created by NewMulti;
+//   - every leaf is at the same depth;
+//   - Len, and the item count cached in each node, match the real count.
+//
+// Errors name the path from the root to the offending node, as the index of
+// the child taken at each step, e.g. "root/2/0".  Validate visits every node,
+// so it's meant for tests and debugging.  A tree can only become invalid
+// through a bug in this package or an Item whose Less isn't a strict weak
+// ordering.
+func (t *BTree) Validate() error {
+	if t.root == nil {
+		if t.length != 0 {
+			return fmt.Errorf("btree: empty tree has Len %d", t.length)
+		}
+		return nil
+	}
+	v := validator{t: t, leafDepth: -1}
+	if len(t.root.items) == 0 && len(t.root.children) > 0 {
+		return v.errorf("is empty, but has children")
+	}
+	count, err := v.node(t.root, nil, nil)
+	if err != nil {
+		return err
+	}
+	if count != t.length {
+		return fmt.Errorf("btree: tree holds %d items, but Len is %d", count, t.length)
+	}
+	return nil
+}
+
+// validator holds the state of a Validate call.
+type validator struct {
+	t         *BTree
+	path      []int
+	leafDepth int
+}
+
+// errorf returns an error about the node at v.path.
+func (v *validator) errorf(format string, args ...interface{}) error {
+	var b strings.Builder
+	b.WriteString("root")
+	for _, i := range v.path {
+		fmt.Fprintf(&b, "/%d", i)
+	}
+	return fmt.Errorf("btree: node %s: %s", b.String(), fmt.Sprintf(format, args...))
+}
+
+// less reports whether a must sort before b: strictly, or in a multiset tree
+// where equivalent items may repeat, not after.
+func (v *validator) less(a, b Item) bool {
+	if v.t.multi {
+		return !b.Less(a)
+	}
+	return a.Less(b)
+}
+
+// node checks the subtree rooted at n, whose items must all fall between lo
+// and hi (either of which may be nil, for no bound), and returns the number of
+// items in it.
+func (v *validator) node(n *node, lo, hi Item) (int, error) {
+	if len(n.children) != 0 && len(n.children) != len(n.items)+1 {
+		return 0, v.errorf("has %d items but %d children", len(n.items), len(n.children))
+	}
+	if len(n.items) > v.t.maxItems() {
+		return 0, v.errorf("has %d items, more than the maximum %d", len(n.items), v.t.maxItems())
+	}
+	if len(v.path) > 0 && len(n.items) < v.t.minItems() {
+		return 0, v.errorf("has %d items, fewer than the minimum %d", len(n.items), v.t.minItems())
+	}
+	for i, item := range n.items {
+		switch {
+		case item == nil:
+			return 0, v.errorf("item %d is nil", i)
+		case i > 0 && !v.less(n.items[i-1], item):
+			return 0, v.errorf("item %d (%v) is out of order after %v", i, item, n.items[i-1])
+		case lo != nil && !v.less(lo, item):
+			return 0, v.errorf("item %d (%v) is out of order after %v in its parent", i, item, lo)
+		case hi != nil && !v.less(item, hi):
+			return 0, v.errorf("item %d (%v) is out of order before %v in its parent", i, item, hi)
+		}
+	}
+	count := len(n.items)
+	if len(n.children) == 0 {
+		if v.leafDepth < 0 {
+			v.leafDepth = len(v.path)
+		} else if len(v.path) != v.leafDepth {
+			return 0, v.errorf("is a leaf at depth %d, but other leaves are at depth %d", len(v.path), v.leafDepth)
+		}
+	}
+	for i, child := range n.children {
+		clo, chi := lo, hi
+		if i > 0 {
+			clo = n.items[i-1]
+		}
+		if i < len(n.items) {
+			chi = n.items[i]
+		}
+		v.path = append(v.path, i)
+		c, err := v.node(child, clo, chi)
+		if err != nil {
+			return 0, err
+		}
+		v.path = v.path[:len(v.path)-1]
+		count += c
+	}
+	if n.size != count {
+		return 0, v.errorf("holds %d items, but its cached size is %d", count, n.size)
+	}
+	return count, nil
+}
@@ -0,0 +1,117 @@
+// Copyright 2014 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package btree
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	tr := New(*btreeDegree)
+	if err := tr.Validate(); err != nil {
+		t.Fatalf("empty tree: %v", err)
+	}
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 5000; i++ {
+		switch v := Int(r.Intn(1000)); r.Intn(4) {
+		case 0, 1:
+			tr.ReplaceOrInsert(v)
+		case 2:
+			tr.Delete(v)
+		case 3:
+			tr.DeleteRange(v, v+5)
+		}
+		if i%100 == 0 {
+			if err := tr.Validate(); err != nil {
+				t.Fatalf("after %d operations: %v", i, err)
+			}
+		}
+	}
+	for tr.Len() > 0 {
+		tr.DeleteMin()
+	}
+	if err := tr.Validate(); err != nil {
+		t.Fatalf("emptied tree: %v", err)
+	}
+
+	m := NewMulti(3)
+	for i := 0; i < 1000; i++ {
+		m.InsertNoReplace(Int(i % 10))
+	}
+	if err := m.Validate(); err != nil {
+		t.Fatalf("multiset: %v", err)
+	}
+}
+
+func TestValidateErrors(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		corrupt func(tr *BTree)
+		want    string
+	}{
+		{"length", func(tr *BTree) { tr.length++ }, "Len is 1001"},
+		{"children", func(tr *BTree) {
+			n := tr.root.children[1]
+			n.children = n.children[:len(n.children)-1]
+		}, "node root/1: has"},
+		{"swapped items", func(tr *BTree) {
+			n := tr.root.children[0].children[2]
+			n.items[0], n.items[1] = n.items[1], n.items[0]
+		}, "node root/0/2: item 1"},
+		{"out of range", func(tr *BTree) {
+			tr.root.children[1].children[0].items[0] = Int(-1)
+		}, "node root/1/0: item 0 (-1) is out of order after"},
+		{"duplicate", func(tr *BTree) {
+			n := tr.root.children[0].children[0]
+			n.items[1] = n.items[0]
+		}, "node root/0/0: item 1"},
+		{"underfull", func(tr *BTree) {
+			n := tr.root.children[0].children[0]
+			n.items = n.items[:1]
+			if len(n.children) > 0 {
+				n.children = n.children[:2]
+			}
+		}, "node root/0/0: has 1 items, fewer than the minimum 2"},
+		{"size", func(tr *BTree) {
+			tr.root.children[2].size++
+		}, "node root/2: holds"},
+	} {
+		tr := New(3)
+		for i := 0; i < 1000; i++ {
+			tr.ReplaceOrInsert(Int(i))
+		}
+		test.corrupt(tr)
+		err := tr.Validate()
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("%s: got error %v, want one containing %q", test.name, err, test.want)
+		}
+	}
+}
+
+func TestValidateLeafDepth(t *testing.T) {
+	tr := New(3)
+	for i := 0; i < 1000; i++ {
+		tr.ReplaceOrInsert(Int(i))
+	}
+	// Hoist a grandchild of the root up a level, leaving its leaves shallower
+	// than the rest.
+	tr.root.children[1] = tr.root.children[1].children[0]
+	err := tr.Validate()
+	if err == nil || !strings.Contains(err.Error(), "node root/1/0") || !strings.Contains(err.Error(), "is a leaf at depth") {
+		t.Fatalf("got error %v", err)
+	}
+}